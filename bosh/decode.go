@@ -0,0 +1,165 @@
+package bosh
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+type DecodeOption func(*decodeOptions)
+
+type decodeOptions struct {
+	disallowUnknownFields bool
+}
+
+// DisallowUnknownFields makes Decode fail when the subtree holds a key that
+// does not map to a field of the target struct.
+func DisallowUnknownFields() DecodeOption {
+	return func(o *decodeOptions) {
+		o.disallowUnknownFields = true
+	}
+}
+
+// Decode decodes the value a lens resolves to into target, honouring yaml
+// struct tags. An empty lens decodes all of the properties.
+func (p Properties) Decode(lens string, target interface{}, opts ...DecodeOption) error {
+	options := decodeOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var value interface{} = p
+	if lens != "" {
+		var err error
+		value, err = p.Find(lens)
+		if err != nil {
+			return err
+		}
+	}
+
+	if options.disallowUnknownFields {
+		if err := checkKnownFields(value, reflect.TypeOf(target), lens); err != nil {
+			return err
+		}
+	}
+
+	b, err := yaml.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("decoding %q: %w", lens, err)
+	}
+
+	if err := yaml.Unmarshal(b, target); err != nil {
+		return fmt.Errorf("decoding %q: %w", lens, err)
+	}
+
+	return nil
+}
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// checkKnownFields walks value alongside the type it will be decoded into and
+// reports the first map key that has no corresponding struct field.
+func checkKnownFields(value interface{}, t reflect.Type, path string) error {
+	for t != nil && t.Kind() == reflect.Ptr {
+		if t.Implements(unmarshalerType) {
+			return nil
+		}
+		t = t.Elem()
+	}
+
+	if t == nil || value == nil || reflect.PtrTo(t).Implements(unmarshalerType) {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		fields := map[string]reflect.Type{}
+		if inlinesMap := collectFields(t, fields); inlinesMap {
+			return nil
+		}
+
+		props, err := ToProperties(value)
+		if err != nil {
+			return nil
+		}
+
+		for k, e := range props {
+			key := fmt.Sprint(k)
+			fieldType, known := fields[key]
+			if !known {
+				return fmt.Errorf("decoding %q: unknown field %q", path, key)
+			}
+			if err := checkKnownFields(e, fieldType, joinLens(path, key)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		props, err := ToProperties(value)
+		if err != nil {
+			return nil
+		}
+		for k, e := range props {
+			if err := checkKnownFields(e, t.Elem(), joinLens(path, fmt.Sprint(k))); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		list, ok := listElements(value)
+		if !ok {
+			return nil
+		}
+		for i, e := range list {
+			if err := checkKnownFields(e, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// collectFields gathers the yaml keys of a struct, descending into inlined
+// structs. It reports whether the struct inlines a map, which accepts any key.
+func collectFields(t reflect.Type, fields map[string]reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		tag := strings.Split(field.Tag.Get("yaml"), ",")
+		if tag[0] == "-" {
+			continue
+		}
+
+		inline := false
+		for _, flag := range tag[1:] {
+			if flag == "inline" {
+				inline = true
+			}
+		}
+
+		if inline {
+			ft := field.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Map {
+				return true
+			}
+			if ft.Kind() == reflect.Struct && collectFields(ft, fields) {
+				return true
+			}
+			continue
+		}
+
+		name := tag[0]
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return false
+}
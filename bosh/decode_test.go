@@ -0,0 +1,96 @@
+package bosh_test
+
+import (
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type securityGroup struct {
+	Name  string `yaml:"name"`
+	Rules []struct {
+		Protocol    string `yaml:"protocol"`
+		Destination string `yaml:"destination"`
+		Ports       string `yaml:"ports"`
+	} `yaml:"rules"`
+}
+
+var _ = Describe("Decode", func() {
+	var p bosh.Properties
+
+	BeforeEach(func() {
+		manifest, err := bosh.ParseManifest([]byte(`---
+instance_groups:
+- name: cloud_controller
+  jobs:
+  - name: cloud_controller_ng
+    properties:
+      cc:
+        security_group_definitions:
+        - name: public_networks
+          rules:
+          - protocol: all
+            destination: 0.0.0.0-9.255.255.255
+        - name: dns
+          rules:
+          - protocol: tcp
+            destination: 0.0.0.0/0
+            ports: "53"
+            log: true
+        default_running_security_groups: [public_networks, dns]
+`))
+		Expect(err).NotTo(HaveOccurred())
+		p = manifest.MustFindInstanceGroupNamed("cloud_controller").MustFindJob("cloud_controller_ng").Properties()
+	})
+
+	It("decodes the subtree into the target", func() {
+		var groups []securityGroup
+		Expect(p.Decode("cc.security_group_definitions", &groups)).To(Succeed())
+
+		Expect(groups).To(HaveLen(2))
+		Expect(groups[0].Name).To(Equal("public_networks"))
+		Expect(groups[1].Rules[0].Ports).To(Equal("53"))
+	})
+
+	It("decodes scalars and lists", func() {
+		var defaults []string
+		Expect(p.Decode("cc.default_running_security_groups", &defaults)).To(Succeed())
+		Expect(defaults).To(Equal([]string{"public_networks", "dns"}))
+	})
+
+	It("decodes all of the properties with an empty lens", func() {
+		var cc struct {
+			CC struct {
+				Defaults []string `yaml:"default_running_security_groups"`
+			} `yaml:"cc"`
+		}
+		Expect(p.Decode("", &cc)).To(Succeed())
+		Expect(cc.CC.Defaults).To(HaveLen(2))
+	})
+
+	It("rejects unknown fields when asked to", func() {
+		var groups []securityGroup
+		err := p.Decode("cc.security_group_definitions", &groups, bosh.DisallowUnknownFields())
+		Expect(err).To(MatchError(`decoding "cc.security_group_definitions[1].rules[0]": unknown field "log"`))
+	})
+
+	It("accepts unknown fields that an inlined map collects", func() {
+		var groups []struct {
+			Name  string                 `yaml:"name"`
+			Extra map[string]interface{} `yaml:",inline"`
+		}
+		Expect(p.Decode("cc.security_group_definitions", &groups, bosh.DisallowUnknownFields())).To(Succeed())
+		Expect(groups[0].Extra).To(HaveKey("rules"))
+	})
+
+	It("fails when the lens does not resolve", func() {
+		var groups []securityGroup
+		Expect(p.Decode("cc.missing", &groups)).To(MatchError(`value not found at segment "missing" of lens "cc.missing"`))
+	})
+
+	It("fails when the value does not fit the target", func() {
+		var count int
+		Expect(p.Decode("cc.default_running_security_groups", &count)).To(MatchError(ContainSubstring(`decoding "cc.default_running_security_groups": yaml: unmarshal errors`)))
+	})
+})
@@ -0,0 +1,131 @@
+package bosh
+
+import "fmt"
+
+// PropertySource is the layer of a manifest that an effective property value
+// was taken from, in increasing order of precedence.
+type PropertySource int
+
+const (
+	SourceSpecDefault PropertySource = iota + 1
+	SourceManifest
+	SourceInstanceGroup
+	SourceJob
+)
+
+func (s PropertySource) String() string {
+	switch s {
+	case SourceSpecDefault:
+		return "spec default"
+	case SourceManifest:
+		return "manifest"
+	case SourceInstanceGroup:
+		return "instance group"
+	case SourceJob:
+		return "job"
+	}
+	return fmt.Sprintf("PropertySource(%d)", int(s))
+}
+
+// ResolvedProperties are the properties a job is rendered with, together with
+// the layer each leaf value came from. Sources is keyed by the lens of every
+// leaf, where lists count as leaves since they are never merged.
+type ResolvedProperties struct {
+	Properties Properties
+	Sources    map[string]PropertySource
+}
+
+// Source returns the layer the value at lens came from, or zero when the lens
+// is not a leaf of the resolved properties.
+func (r *ResolvedProperties) Source(lens string) PropertySource {
+	return r.Sources[lens]
+}
+
+// Spec is the part of a release job spec that EffectiveProperties needs. It
+// is implemented by *release.JobSpec.
+type Spec interface {
+	// PropertyNames returns the dotted names of the declared properties.
+	PropertyNames() []string
+	// Defaults returns the declared defaults as nested properties.
+	Defaults() Properties
+}
+
+type propertyLayer struct {
+	source     PropertySource
+	properties Properties
+}
+
+// EffectiveProperties resolves the properties a job is rendered with, the way
+// the BOSH director does. A job that sets properties, even an empty map, sees
+// only those; otherwise it sees the instance group properties deep merged
+// over the global properties of the manifest.
+//
+// With a spec, only the properties it declares are kept, and declared
+// properties that are unset or null take the spec default. Without one, spec
+// may be nil and every property is kept.
+func (m *Manifest) EffectiveProperties(instanceGroup, job string, spec Spec) (*ResolvedProperties, error) {
+	ig, err := m.LookupInstanceGroup(instanceGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	j, err := ig.LookupJob(job)
+	if err != nil {
+		return nil, err
+	}
+
+	layers := []propertyLayer{{SourceJob, j.Properties()}}
+	if j.Properties() == nil {
+		layers = []propertyLayer{{SourceManifest, m.Properties}, {SourceInstanceGroup, ig.Properties()}}
+	}
+
+	merged := Properties{}
+	for _, layer := range layers {
+		merged.Merge(layer.properties, MergeOverwrite)
+	}
+
+	// The value of a leaf comes from the highest layer that sets it, since
+	// every layer overwrites the ones below.
+	source := func(lens string) PropertySource {
+		for i := len(layers) - 1; i >= 0; i-- {
+			if _, err := layers[i].properties.Lookup(lens); err == nil {
+				return layers[i].source
+			}
+		}
+		return 0
+	}
+
+	resolved := &ResolvedProperties{
+		Properties: Properties{},
+		Sources:    map[string]PropertySource{},
+	}
+
+	if spec == nil {
+		resolved.Properties = merged
+		walkLeaves(merged, "", func(lens string) {
+			resolved.Sources[lens] = source(lens)
+		})
+		return resolved, nil
+	}
+
+	defaults := spec.Defaults()
+	for _, name := range spec.PropertyNames() {
+		value, err := merged.Lookup(name)
+		valueSource := source
+		if err != nil || value == nil {
+			if value, err = defaults.Lookup(name); err != nil {
+				continue
+			}
+			valueSource = func(string) PropertySource { return SourceSpecDefault }
+		}
+
+		if err := resolved.Properties.Set(name, CopyValue(value)); err != nil {
+			return nil, err
+		}
+		walkLeaves(value, name, func(lens string) {
+			resolved.Sources[lens] = valueSource(lens)
+		})
+	}
+
+	return resolved, nil
+}
@@ -0,0 +1,164 @@
+package bosh_test
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeSpec declares properties the way a release job spec does.
+type fakeSpec struct {
+	names    []string
+	defaults bosh.Properties
+}
+
+func (s fakeSpec) PropertyNames() []string {
+	names := append([]string(nil), s.names...)
+	sort.Strings(names)
+	return names
+}
+
+func (s fakeSpec) Defaults() bosh.Properties {
+	return s.defaults
+}
+
+var _ = Describe("EffectiveProperties", func() {
+	var (
+		manifest *bosh.Manifest
+		spec     fakeSpec
+	)
+
+	BeforeEach(func() {
+		var err error
+		manifest, err = bosh.ParseManifest([]byte(`---
+name: cf
+properties:
+  router:
+    port: 8080
+    status:
+      user: admin
+  ssl.enabled: true
+instance_groups:
+- name: router
+  properties:
+    router:
+      port: 8081
+      logging_level: info
+  jobs:
+  - name: gorouter
+    properties:
+      router:
+        logging_level: debug
+        backends: [a, b]
+        status:
+          password: null
+        typo: x
+  - name: route_registrar
+  - name: empty
+    properties: {}
+`))
+		Expect(err).NotTo(HaveOccurred())
+
+		spec = fakeSpec{
+			names: []string{"router.port", "router.logging_level", "router.backends", "router.status.user", "router.status.password", "ssl.enabled"},
+			defaults: bosh.Properties{
+				"router": bosh.Properties{
+					"port":     80,
+					"backends": []interface{}{"default"},
+					"status": bosh.Properties{
+						"user":     "router-status",
+						"password": "changeme",
+					},
+				},
+			},
+		}
+	})
+
+	It("renders a job that sets properties with those alone", func() {
+		resolved, err := manifest.EffectiveProperties("router", "gorouter", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(resolved.Properties).To(Equal(bosh.Properties{
+			"router": bosh.Properties{
+				"logging_level": "debug",
+				"backends":      []interface{}{"a", "b"},
+				"status":        bosh.Properties{"password": nil},
+				"typo":          "x",
+			},
+		}))
+		Expect(resolved.Source("router.logging_level")).To(Equal(bosh.SourceJob))
+	})
+
+	It("renders a job without properties with the instance group properties merged over the global ones", func() {
+		resolved, err := manifest.EffectiveProperties("router", "route_registrar", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		p := resolved.Properties
+		Expect(p.FindInt("router.port")).To(Equal(8081))
+		Expect(p.FindString("router.logging_level")).To(Equal("info"))
+		Expect(p.FindString("router.status.user")).To(Equal("admin"))
+		Expect(p.FindBool(`"ssl.enabled"`)).To(BeTrue())
+
+		Expect(resolved.Sources).To(Equal(map[string]bosh.PropertySource{
+			"router.port":          bosh.SourceInstanceGroup,
+			"router.logging_level": bosh.SourceInstanceGroup,
+			"router.status.user":   bosh.SourceManifest,
+			`"ssl.enabled"`:        bosh.SourceManifest,
+		}))
+		Expect(resolved.Source("router.port").String()).To(Equal("instance group"))
+	})
+
+	It("renders a job with empty properties with nothing from the other layers", func() {
+		resolved, err := manifest.EffectiveProperties("router", "empty", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resolved.Properties).To(BeEmpty())
+	})
+
+	It("keeps only declared properties and fills in spec defaults", func() {
+		resolved, err := manifest.EffectiveProperties("router", "gorouter", spec)
+		Expect(err).NotTo(HaveOccurred())
+
+		p := resolved.Properties
+		Expect(p.FindString("router.logging_level")).To(Equal("debug"))
+		Expect(p.FindStringSlice("router.backends")).To(Equal([]string{"a", "b"}))
+		Expect(p.FindInt("router.port")).To(Equal(80))
+		Expect(p.FindString("router.status.user")).To(Equal("router-status"))
+		Expect(p.FindString("router.status.password")).To(Equal("changeme"))
+
+		_, err = p.Lookup("router.typo")
+		Expect(errors.Is(err, bosh.ErrPropertyNotFound)).To(BeTrue())
+		_, err = p.Lookup(`"ssl.enabled"`)
+		Expect(errors.Is(err, bosh.ErrPropertyNotFound)).To(BeTrue())
+
+		Expect(resolved.Sources).To(Equal(map[string]bosh.PropertySource{
+			"router.logging_level":   bosh.SourceJob,
+			"router.backends":        bosh.SourceJob,
+			"router.port":            bosh.SourceSpecDefault,
+			"router.status.user":     bosh.SourceSpecDefault,
+			"router.status.password": bosh.SourceSpecDefault,
+		}))
+	})
+
+	It("does not modify the manifest or the spec defaults", func() {
+		resolved, err := manifest.EffectiveProperties("router", "route_registrar", spec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resolved.Properties.Set("router.port", 1)).To(Succeed())
+		Expect(resolved.Properties.Set("router.status.password", "x")).To(Succeed())
+
+		Expect(manifest.Properties.FindInt("router.port")).To(Equal(8080))
+		Expect(manifest.MustFindInstanceGroupNamed("router").Properties().FindInt("router.port")).To(Equal(8081))
+		Expect(spec.defaults.FindString("router.status.password")).To(Equal("changeme"))
+	})
+
+	It("returns an error for a missing instance group or job", func() {
+		_, err := manifest.EffectiveProperties("nats", "gorouter", nil)
+		Expect(errors.Is(err, bosh.ErrInstanceGroupNotFound)).To(BeTrue())
+
+		_, err = manifest.EffectiveProperties("router", "nats", nil)
+		Expect(errors.Is(err, bosh.ErrJobNotFound)).To(BeTrue())
+	})
+})
@@ -0,0 +1,31 @@
+package bosh
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors returned, wrapped, by the Lookup family of methods. Use
+// errors.Is to test for them.
+var (
+	ErrInstanceGroupNotFound = errors.New("instance group not found")
+	ErrJobNotFound           = errors.New("job not found")
+	ErrPropertyNotFound      = errors.New("property not found")
+	ErrTypeMismatch          = errors.New("type mismatch")
+)
+
+// TypeMismatchError is returned when the value at Path is not of the type
+// needed to read or traverse it. It matches ErrTypeMismatch with errors.Is.
+type TypeMismatchError struct {
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (e *TypeMismatchError) Error() string {
+	return fmt.Sprintf("value at %q is %s, not %s", e.Path, e.Actual, e.Expected)
+}
+
+func (e *TypeMismatchError) Unwrap() error {
+	return ErrTypeMismatch
+}
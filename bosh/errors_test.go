@@ -0,0 +1,118 @@
+package bosh_test
+
+import (
+	"errors"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Lookups", func() {
+	var manifest *bosh.Manifest
+
+	BeforeEach(func() {
+		manifest = &bosh.Manifest{
+			Jobs: []*bosh.Job{bosh.NewJob("legacyJob-partition-random-guid")},
+			InstanceGroups: []*bosh.InstanceGroup{
+				bosh.NewInstanceGroup("router", []*bosh.Job{bosh.NewJob("gorouter")}),
+			},
+		}
+	})
+
+	Describe("LookupInstanceGroup", func() {
+		It("returns the instance group", func() {
+			ig, err := manifest.LookupInstanceGroup("router")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ig.Name()).To(Equal("router"))
+		})
+
+		It("returns ErrInstanceGroupNotFound when there is no match", func() {
+			_, err := manifest.LookupInstanceGroup("uaa")
+			Expect(errors.Is(err, bosh.ErrInstanceGroupNotFound)).To(BeTrue())
+			Expect(err).To(MatchError("instance group not found: 'uaa'"))
+		})
+	})
+
+	Describe("InstanceGroup LookupJob", func() {
+		It("returns the job", func() {
+			job, err := manifest.InstanceGroups[0].LookupJob("gorouter")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(job.Name()).To(Equal("gorouter"))
+		})
+
+		It("returns ErrJobNotFound when there is no match", func() {
+			_, err := manifest.InstanceGroups[0].LookupJob("haproxy")
+			Expect(errors.Is(err, bosh.ErrJobNotFound)).To(BeTrue())
+			Expect(err).To(MatchError("job not found: 'haproxy' in instance group 'router'"))
+		})
+	})
+
+	Describe("Manifest LookupJob", func() {
+		It("returns jobs and instance groups", func() {
+			job, err := manifest.LookupJob("legacyJob")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(job.Name()).To(Equal("legacyJob-partition-random-guid"))
+
+			job, err = manifest.LookupJob("router")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(job.Name()).To(Equal("router"))
+		})
+
+		It("returns ErrJobNotFound when there is no match", func() {
+			_, err := manifest.LookupJob("uaa")
+			Expect(errors.Is(err, bosh.ErrJobNotFound)).To(BeTrue())
+		})
+	})
+
+	Describe("Properties Lookup", func() {
+		var p bosh.Properties
+
+		BeforeEach(func() {
+			p = bosh.Properties{
+				"router": bosh.Properties{
+					"port":   80,
+					"routes": []interface{}{"a"},
+				},
+				"an": map[string]string{
+					"unusual": "property",
+				},
+			}
+		})
+
+		It("returns the value", func() {
+			Expect(p.Lookup("router.port")).To(Equal(80))
+		})
+
+		It("returns ErrPropertyNotFound when the lens does not resolve", func() {
+			_, err := p.Lookup("router.tls.port")
+			Expect(errors.Is(err, bosh.ErrPropertyNotFound)).To(BeTrue())
+
+			_, err = p.Lookup("router.routes[3]")
+			Expect(errors.Is(err, bosh.ErrPropertyNotFound)).To(BeTrue())
+		})
+
+		It("returns a TypeMismatchError instead of panicking on unexpected intermediate values", func() {
+			_, err := p.Lookup("router.port.value")
+			Expect(errors.Is(err, bosh.ErrTypeMismatch)).To(BeTrue())
+
+			var mismatch *bosh.TypeMismatchError
+			Expect(errors.As(err, &mismatch)).To(BeTrue())
+			Expect(mismatch).To(Equal(&bosh.TypeMismatchError{Path: "router.port", Expected: "a map", Actual: "int"}))
+			Expect(err).To(MatchError(`value not a map at segment "value" of lens "router.port.value"`))
+
+			_, err = p.Lookup("an.unusual.property")
+			Expect(errors.As(err, &mismatch)).To(BeTrue())
+			Expect(mismatch).To(Equal(&bosh.TypeMismatchError{Path: "an.unusual", Expected: "a map", Actual: "string"}))
+		})
+
+		It("returns a TypeMismatchError from the typed finders", func() {
+			_, err := p.FindString("router.port")
+
+			var mismatch *bosh.TypeMismatchError
+			Expect(errors.As(err, &mismatch)).To(BeTrue())
+			Expect(err).To(MatchError(`value at "router.port" is int, not a string`))
+		})
+	})
+})
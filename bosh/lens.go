@@ -0,0 +1,181 @@
+package bosh
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A lens addresses a value inside Properties. Keys are separated by dots and
+// may be followed by any number of bracketed list accessors:
+//
+//	router.status.user          nested keys
+//	uaa.clients.foo.scope[0]    the first element of a list
+//	router.routes[name=api]     the element of a list of maps whose name is api
+//	"ssl.enabled" or ssl\.enabled   a single key containing a dot
+//
+// Negative indices count from the end of the list.
+
+// LensError describes which segment of a lens could not be parsed or resolved.
+// When the lens could be parsed Err is ErrPropertyNotFound or a
+// *TypeMismatchError.
+type LensError struct {
+	Lens    string
+	Segment string
+	Reason  string
+	Err     error
+}
+
+func (e *LensError) Error() string {
+	return fmt.Sprintf("%s at segment %q of lens %q", e.Reason, e.Segment, e.Lens)
+}
+
+func (e *LensError) Unwrap() error {
+	return e.Err
+}
+
+type segmentKind int
+
+const (
+	keySegment segmentKind = iota
+	indexSegment
+	selectorSegment
+)
+
+type lensSegment struct {
+	kind  segmentKind
+	key   string
+	index int
+	value string
+	text  string
+}
+
+// ValidateLens returns a *LensError when a lens cannot be parsed, so that a
+// malformed lens can be rejected before it is used to look anything up.
+func ValidateLens(lens string) error {
+	_, err := parseLens(lens)
+	return err
+}
+
+func parseLens(lens string) ([]lensSegment, error) {
+	var segments []lensSegment
+
+	fail := func(segment, reason string) ([]lensSegment, error) {
+		return nil, &LensError{Lens: lens, Segment: segment, Reason: reason}
+	}
+
+	if lens == "" {
+		return fail("", "empty lens")
+	}
+
+	i := 0
+	for i < len(lens) {
+		start := i
+
+		switch lens[i] {
+		case '[':
+			end := strings.IndexByte(lens[i:], ']')
+			if end < 0 {
+				return fail(lens[i:], "unterminated bracket")
+			}
+			segment, err := parseBracket(lens[i+1 : i+end])
+			segment.text = lens[i : i+end+1]
+			if err != nil {
+				return fail(segment.text, err.Error())
+			}
+			segments = append(segments, segment)
+			i += end + 1
+		case '"':
+			key := &strings.Builder{}
+			closed := false
+			for i++; i < len(lens); i++ {
+				if lens[i] == '\\' && i+1 < len(lens) {
+					i++
+					key.WriteByte(lens[i])
+					continue
+				}
+				if lens[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				key.WriteByte(lens[i])
+			}
+			if !closed {
+				return fail(lens[start:], "unterminated quote")
+			}
+			segments = append(segments, lensSegment{kind: keySegment, key: key.String(), text: lens[start:i]})
+		default:
+			key := &strings.Builder{}
+			for ; i < len(lens) && lens[i] != '.' && lens[i] != '['; i++ {
+				if lens[i] == '\\' && i+1 < len(lens) {
+					i++
+				}
+				key.WriteByte(lens[i])
+			}
+			if key.Len() == 0 {
+				return fail(lens[start:i], "empty segment")
+			}
+			segments = append(segments, lensSegment{kind: keySegment, key: key.String(), text: lens[start:i]})
+		}
+
+		if i == len(lens) {
+			break
+		}
+
+		switch lens[i] {
+		case '.':
+			i++
+			if i == len(lens) || lens[i] == '.' || lens[i] == '[' {
+				return fail(lens[start:i], "empty segment")
+			}
+		case '[':
+		default:
+			return fail(lens[start:], "expected '.' or '['")
+		}
+	}
+
+	return segments, nil
+}
+
+func parseBracket(body string) (lensSegment, error) {
+	if eq := strings.IndexByte(body, '='); eq >= 0 {
+		key, value := body[:eq], body[eq+1:]
+		if key == "" {
+			return lensSegment{}, errors.New("selector has no key")
+		}
+		return lensSegment{kind: selectorSegment, key: key, value: value}, nil
+	}
+
+	index, err := strconv.Atoi(body)
+	if err != nil {
+		return lensSegment{}, errors.New("invalid index")
+	}
+	return lensSegment{kind: indexSegment, index: index}, nil
+}
+
+// lensPrefix renders parsed segments back into a lens.
+func lensPrefix(segments []lensSegment) string {
+	s := ""
+	for i, segment := range segments {
+		if segment.kind == keySegment && i > 0 {
+			s += "."
+		}
+		s += segment.text
+	}
+	return s
+}
+
+// joinLens appends a key to a lens, quoting the key when it would otherwise be
+// read back as more than one segment.
+func joinLens(path, key string) string {
+	segment := key
+	if strings.ContainsAny(key, `."[]\`) {
+		segment = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(key) + `"`
+	}
+	if path == "" {
+		return segment
+	}
+	return path + "." + segment
+}
@@ -1,38 +1,128 @@
 package bosh
 
 import (
-	"errors"
 	"fmt"
 	"regexp"
-	"strings"
+
+	"gopkg.in/yaml.v2"
 )
 
 type StagedManifestResponse struct {
 	Manifest *Manifest `yaml:"manifest"`
 }
 
+// Manifest is a BOSH deployment manifest. One returned by ParseManifest also
+// remembers the document it was parsed from, so that marshalling it keeps the
+// keys the structs do not model.
 type Manifest struct {
-	Jobs           []*Job           `yaml:"jobs"`
-	InstanceGroups []*InstanceGroup `yaml:"instance_groups"`
-	Releases       []Release        `yaml:"releases"`
-	Variables      []Variable       `yaml:"variables"`
+	Name           string            `yaml:"name,omitempty"`
+	DirectorUUID   string            `yaml:"director_uuid,omitempty"`
+	Features       *Features         `yaml:"features,omitempty"`
+	Releases       []Release         `yaml:"releases"`
+	Stemcells      []Stemcell        `yaml:"stemcells,omitempty"`
+	Update         *Update           `yaml:"update,omitempty"`
+	Jobs           []*Job            `yaml:"jobs"`
+	InstanceGroups []*InstanceGroup  `yaml:"instance_groups"`
+	Addons         []*Addon          `yaml:"addons,omitempty"`
+	Properties     Properties        `yaml:"properties,omitempty"`
+	Variables      []Variable        `yaml:"variables"`
+	Tags           map[string]string `yaml:"tags,omitempty"`
+
+	raw yaml.MapSlice
+}
+
+type Features struct {
+	ConvergeVariables    *bool `yaml:"converge_variables,omitempty"`
+	RandomizeAZPlacement *bool `yaml:"randomize_az_placement,omitempty"`
+	UseDNSAddresses      *bool `yaml:"use_dns_addresses,omitempty"`
+	UseShortDNSAddresses *bool `yaml:"use_short_dns_addresses,omitempty"`
+	UseLinkDNSNames      *bool `yaml:"use_link_dns_names,omitempty"`
+	UseTmpfsConfig       *bool `yaml:"use_tmpfs_config,omitempty"`
+}
+
+type Stemcell struct {
+	Alias   string `yaml:"alias"`
+	OS      string `yaml:"os,omitempty"`
+	Name    string `yaml:"name,omitempty"`
+	Version string `yaml:"version"`
+}
+
+// Update holds the update block of either the manifest or an instance group.
+// Watch times may be a single number of milliseconds or a "min-max" range and
+// max_in_flight may be an absolute number or a percentage, so they are kept as
+// the raw decoded values.
+type Update struct {
+	Canaries        interface{} `yaml:"canaries,omitempty"`
+	MaxInFlight     interface{} `yaml:"max_in_flight,omitempty"`
+	CanaryWatchTime interface{} `yaml:"canary_watch_time,omitempty"`
+	UpdateWatchTime interface{} `yaml:"update_watch_time,omitempty"`
+	Serial          *bool       `yaml:"serial,omitempty"`
+	VMStrategy      string      `yaml:"vm_strategy,omitempty"`
+}
+
+type Addon struct {
+	Name    string     `yaml:"name"`
+	Jobs    []*Job     `yaml:"jobs"`
+	Include *Placement `yaml:"include,omitempty"`
+	Exclude *Placement `yaml:"exclude,omitempty"`
+}
+
+type Placement struct {
+	Stemcell       []PlacementStemcell `yaml:"stemcell,omitempty"`
+	Deployments    []string            `yaml:"deployments,omitempty"`
+	Jobs           []PlacementJob      `yaml:"jobs,omitempty"`
+	InstanceGroups []string            `yaml:"instance_groups,omitempty"`
+	Networks       []string            `yaml:"networks,omitempty"`
+	Teams          []string            `yaml:"teams,omitempty"`
+	AZs            []string            `yaml:"azs,omitempty"`
+	Lifecycle      string              `yaml:"lifecycle,omitempty"`
+}
+
+type PlacementStemcell struct {
+	OS string `yaml:"os"`
+}
+
+type PlacementJob struct {
+	Name    string `yaml:"name"`
+	Release string `yaml:"release"`
 }
 
 type Variable struct {
-	Name    string                 `yaml:"name"`
-	Type    string                 `yaml:"type"`
-	Options map[string]interface{} `yaml:"options"`
+	Name       string                 `yaml:"name"`
+	Type       string                 `yaml:"type"`
+	Options    map[string]interface{} `yaml:"options"`
+	Consumes   map[string]interface{} `yaml:"consumes,omitempty"`
+	UpdateMode string                 `yaml:"update_mode,omitempty"`
 }
 
 type Release struct {
-	Name    string `yaml:"name"`
+	Name     string           `yaml:"name"`
+	Version  string           `yaml:"version"`
+	URL      string           `yaml:"url,omitempty"`
+	SHA1     string           `yaml:"sha1,omitempty"`
+	Stemcell *ReleaseStemcell `yaml:"stemcell,omitempty"`
+}
+
+// ReleaseStemcell identifies the stemcell a compiled release was built
+// against.
+type ReleaseStemcell struct {
+	OS      string `yaml:"os"`
 	Version string `yaml:"version"`
 }
 
 type Job struct {
-	N string                 `yaml:"name"`
-	P Properties             `yaml:"properties"`
-	C map[string]interface{} `yaml:"consumes"`
+	N                         string                     `yaml:"name"`
+	Release                   string                     `yaml:"release,omitempty"`
+	P                         Properties                 `yaml:"properties"`
+	C                         map[string]interface{}     `yaml:"consumes"`
+	Provides                  map[string]interface{}     `yaml:"provides,omitempty"`
+	CustomProviderDefinitions []CustomProviderDefinition `yaml:"custom_provider_definitions,omitempty"`
+}
+
+type CustomProviderDefinition struct {
+	Name       string   `yaml:"name"`
+	Type       string   `yaml:"type"`
+	Properties []string `yaml:"properties,omitempty"`
 }
 
 type OMJob interface {
@@ -61,10 +151,64 @@ func NewJob(name string) *Job {
 type Properties map[interface{}]interface{}
 
 type InstanceGroup struct {
-	N string     `yaml:"name"`
-	I int        `yaml:"instances"`
-	J []*Job     `yaml:"jobs"`
-	P Properties `yaml:"properties"`
+	N                  string            `yaml:"name"`
+	AZs                []string          `yaml:"azs,omitempty"`
+	I                  int               `yaml:"instances"`
+	Lifecycle          string            `yaml:"lifecycle,omitempty"`
+	J                  []*Job            `yaml:"jobs"`
+	VMType             string            `yaml:"vm_type,omitempty"`
+	VMResources        *VMResources      `yaml:"vm_resources,omitempty"`
+	VMExtensions       []string          `yaml:"vm_extensions,omitempty"`
+	Stemcell           string            `yaml:"stemcell,omitempty"`
+	PersistentDisk     int               `yaml:"persistent_disk,omitempty"`
+	PersistentDiskType string            `yaml:"persistent_disk_type,omitempty"`
+	Networks           []Network         `yaml:"networks,omitempty"`
+	Update             *Update           `yaml:"update,omitempty"`
+	MigratedFrom       []MigratedFrom    `yaml:"migrated_from,omitempty"`
+	Env                *InstanceGroupEnv `yaml:"env,omitempty"`
+	P                  Properties        `yaml:"properties"`
+}
+
+type VMResources struct {
+	CPU               int `yaml:"cpu"`
+	RAM               int `yaml:"ram"`
+	EphemeralDiskSize int `yaml:"ephemeral_disk_size"`
+}
+
+type Network struct {
+	Name      string   `yaml:"name"`
+	StaticIPs []string `yaml:"static_ips,omitempty"`
+	Default   []string `yaml:"default,omitempty"`
+}
+
+type MigratedFrom struct {
+	Name string `yaml:"name"`
+	AZ   string `yaml:"az,omitempty"`
+}
+
+type InstanceGroupEnv struct {
+	Bosh             *BoshEnv `yaml:"bosh,omitempty"`
+	PersistentDiskFS string   `yaml:"persistent_disk_fs,omitempty"`
+}
+
+type BoshEnv struct {
+	Password              string      `yaml:"password,omitempty"`
+	KeepRootPassword      *bool       `yaml:"keep_root_password,omitempty"`
+	RemoveDevTools        *bool       `yaml:"remove_dev_tools,omitempty"`
+	RemoveStaticLibraries *bool       `yaml:"remove_static_libraries,omitempty"`
+	SwapSize              *int        `yaml:"swap_size,omitempty"`
+	IPv6                  *IPv6Env    `yaml:"ipv6,omitempty"`
+	JobDir                *JobDirEnv  `yaml:"job_dir,omitempty"`
+	Agent                 interface{} `yaml:"agent,omitempty"`
+}
+
+type IPv6Env struct {
+	Enable bool `yaml:"enable"`
+}
+
+type JobDirEnv struct {
+	TmpFS     bool   `yaml:"tmpfs"`
+	TmpFSSize string `yaml:"tmpfs_size,omitempty"`
 }
 
 func (ig *InstanceGroup) Name() string {
@@ -124,6 +268,18 @@ func (ig *InstanceGroup) MustFindJob(name string) *Job {
 	return job
 }
 
+// LookupJob is like MustFindJob but returns an error wrapping ErrJobNotFound
+// instead of panicking.
+func (ig *InstanceGroup) LookupJob(name string) (*Job, error) {
+	job := ig.FindJob(name)
+
+	if job == nil {
+		return nil, fmt.Errorf("%w: '%s' in instance group '%s'", ErrJobNotFound, name, ig.Name())
+	}
+
+	return job, nil
+}
+
 func (m *Manifest) InstanceGroupNamedIfNonEmpty(instanceGroupName string) *InstanceGroup {
 	ig := m.InstanceGroupNamed(instanceGroupName)
 	if ig != nil && ig.Instances() > 0 {
@@ -151,52 +307,127 @@ func (m *Manifest) MustFindInstanceGroupNamed(instanceGroupName string) *Instanc
 	return ig
 }
 
+// LookupInstanceGroup is like MustFindInstanceGroupNamed but returns an error
+// wrapping ErrInstanceGroupNotFound instead of panicking.
+func (m *Manifest) LookupInstanceGroup(instanceGroupName string) (*InstanceGroup, error) {
+	ig := m.InstanceGroupNamed(instanceGroupName)
+
+	if ig == nil {
+		return nil, fmt.Errorf("%w: '%s'", ErrInstanceGroupNotFound, instanceGroupName)
+	}
+
+	return ig, nil
+}
+
 func (m *Manifest) JobNamed(name string) (job OMJob) {
+	job, err := m.LookupJob(name)
+	if err != nil {
+		panic(fmt.Sprintf("Unable to find job named: '%s-partition'", name))
+	}
+	return job
+}
+
+// LookupJob is like JobNamed but returns an error wrapping ErrJobNotFound
+// instead of panicking.
+func (m *Manifest) LookupJob(name string) (OMJob, error) {
 	jobName := fmt.Sprintf("%s-partition", name)
 
 	for _, j := range m.Jobs {
 		if matched, err := regexp.MatchString("^"+jobName, j.Name()); err == nil && matched {
-			return j
+			return j, nil
 		}
 	}
 
 	for _, j := range m.Jobs {
 		if matched, err := regexp.MatchString("^"+name, j.Name()); err == nil && matched {
-			return j
+			return j, nil
 		}
 	}
 
 	for _, ig := range m.InstanceGroups {
 		if ig.Name() == name {
-			return ig
+			return ig, nil
 		}
 	}
 
-	panic(fmt.Sprintf("Unable to find job named: '%s'", jobName))
+	return nil, fmt.Errorf("%w: '%s'", ErrJobNotFound, name)
 }
 
+// Find returns the value a lens resolves to. Maps of any type and lists are
+// traversed, so values decoded from JSON can be queried as well as YAML.
 func (p Properties) Find(lens string) (val interface{}, err error) {
-	matchers := strings.Split(lens, ".")
+	return p.Lookup(lens)
+}
 
-	if len(matchers) == 1 {
-		val, found := p[matchers[0]]
-		if !found {
-			return nil, errors.New("value not found")
-		}
-		return val, nil
+// Lookup is Find under the name shared by the other error-returning lookups.
+// Errors wrap ErrPropertyNotFound when the lens does not resolve and a
+// *TypeMismatchError when a value on the way is not a map or list.
+func (p Properties) Lookup(lens string) (interface{}, error) {
+	segments, err := parseLens(lens)
+	if err != nil {
+		return nil, err
 	}
 
-	m := matchers[0]
+	var current interface{} = p
+	for i, s := range segments {
+		notFound := func(reason string) (interface{}, error) {
+			return nil, &LensError{Lens: lens, Segment: s.text, Reason: reason, Err: ErrPropertyNotFound}
+		}
+		mismatch := func(expected string) (interface{}, error) {
+			return nil, &LensError{
+				Lens:    lens,
+				Segment: s.text,
+				Reason:  "value not " + expected,
+				Err: &TypeMismatchError{
+					Path:     lensPrefix(segments[:i]),
+					Expected: expected,
+					Actual:   TypeName(current),
+				},
+			}
+		}
 
-	if next, present := p[m]; present {
-		n, ok := next.(Properties)
-		if !ok {
-			panic("type conversion failed")
+		switch s.kind {
+		case keySegment:
+			next, found, ok := MapValue(current, s.key)
+			if !ok {
+				return mismatch("a map")
+			}
+			if !found {
+				return notFound("value not found")
+			}
+			current = next
+		case indexSegment:
+			list, ok := listElements(current)
+			if !ok {
+				return mismatch("a list")
+			}
+			index := s.index
+			if index < 0 {
+				index += len(list)
+			}
+			if index < 0 || index >= len(list) {
+				return notFound("index out of range")
+			}
+			current = list[index]
+		case selectorSegment:
+			list, ok := listElements(current)
+			if !ok {
+				return mismatch("a list")
+			}
+			current = nil
+			for _, element := range list {
+				if v, found, _ := MapValue(element, s.key); found && fmt.Sprint(v) == s.value {
+					current = element
+					break
+				}
+			}
+			if current == nil {
+				return notFound("no element matches")
+			}
 		}
-		return n.Find(strings.Join(matchers[1:], "."))
-	} else {
-		return nil, errors.New("value not found")
 	}
+
+	return current, nil
 }
 
 func (p Properties) FindString(lens string) (val string, err error) {
@@ -207,7 +438,7 @@ func (p Properties) FindString(lens string) (val string, err error) {
 
 	val, ok := s.(string)
 	if !ok {
-		return "", errors.New("value not a string")
+		return "", &TypeMismatchError{Path: lens, Expected: "a string", Actual: TypeName(s)}
 	}
 
 	return val, nil
@@ -221,7 +452,7 @@ func (p Properties) FindInt(lens string) (val int, err error) {
 
 	val, ok := s.(int)
 	if !ok {
-		return 0, errors.New("value not an integer")
+		return 0, &TypeMismatchError{Path: lens, Expected: "an integer", Actual: TypeName(s)}
 	}
 
 	return val, nil
@@ -235,7 +466,7 @@ func (p Properties) FindBool(lens string) (val bool, err error) {
 
 	val, ok := b.(bool)
 	if !ok {
-		return false, errors.New("value not a boolean")
+		return false, &TypeMismatchError{Path: lens, Expected: "a boolean", Actual: TypeName(b)}
 	}
 
 	return val, nil
@@ -4,7 +4,10 @@ import (
 	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
+
+	"gopkg.in/yaml.v2"
 )
 
 var _ = Describe("Manifest", func() {
@@ -13,6 +16,156 @@ var _ = Describe("Manifest", func() {
 		manifest *bosh.Manifest
 	)
 
+	Describe("decoding a v2 manifest", func() {
+		BeforeEach(func() {
+			manifest = &bosh.Manifest{}
+			err := yaml.Unmarshal([]byte(`---
+name: cf-some-guid
+director_uuid: some-director-uuid
+features:
+  use_dns_addresses: true
+releases:
+- name: routing
+  version: 0.166.0
+  url: https://example.com/routing.tgz
+  sha1: abc123
+  stemcell:
+    os: ubuntu-trusty
+    version: "3468"
+stemcells:
+- alias: default
+  os: ubuntu-trusty
+  version: "3468"
+update:
+  canaries: 1
+  max_in_flight: 20%
+  canary_watch_time: 30000-300000
+  update_watch_time: 30000
+  serial: false
+instance_groups:
+- name: router
+  azs: [z1, z2]
+  instances: 2
+  lifecycle: service
+  vm_type: m3.medium
+  vm_extensions: [router-lb]
+  stemcell: default
+  persistent_disk_type: "10240"
+  networks:
+  - name: default
+    static_ips: [10.0.0.5, 10.0.0.6]
+    default: [dns, gateway]
+  update:
+    max_in_flight: 1
+  migrated_from:
+  - name: router_z1
+    az: z1
+  env:
+    persistent_disk_fs: ext4
+    bosh:
+      keep_root_password: true
+      swap_size: 0
+      job_dir:
+        tmpfs: true
+  jobs:
+  - name: gorouter
+    release: routing
+    provides:
+      gorouter: {as: router}
+    custom_provider_definitions:
+    - name: router-address
+      type: address
+    properties:
+      router:
+        port: 80
+addons:
+- name: syslog
+  jobs:
+  - name: syslog_forwarder
+    release: syslog
+  include:
+    stemcell:
+    - os: ubuntu-trusty
+  exclude:
+    instance_groups: [compilation]
+properties:
+  global: value
+variables:
+- name: router_ca
+  type: certificate
+  update_mode: converge
+  options:
+    is_ca: true
+tags:
+  product: cf
+`), manifest)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("decodes the deployment level fields", func() {
+			Expect(manifest.Name).To(Equal("cf-some-guid"))
+			Expect(manifest.DirectorUUID).To(Equal("some-director-uuid"))
+			Expect(*manifest.Features.UseDNSAddresses).To(BeTrue())
+			Expect(manifest.Features.ConvergeVariables).To(BeNil())
+			Expect(manifest.Releases).To(Equal([]bosh.Release{{
+				Name:     "routing",
+				Version:  "0.166.0",
+				URL:      "https://example.com/routing.tgz",
+				SHA1:     "abc123",
+				Stemcell: &bosh.ReleaseStemcell{OS: "ubuntu-trusty", Version: "3468"},
+			}}))
+			Expect(manifest.Stemcells).To(Equal([]bosh.Stemcell{{Alias: "default", OS: "ubuntu-trusty", Version: "3468"}}))
+			Expect(manifest.Update.Canaries).To(Equal(1))
+			Expect(manifest.Update.MaxInFlight).To(Equal("20%"))
+			Expect(manifest.Update.CanaryWatchTime).To(Equal("30000-300000"))
+			Expect(manifest.Update.UpdateWatchTime).To(Equal(30000))
+			Expect(*manifest.Update.Serial).To(BeFalse())
+			Expect(manifest.Properties).To(Equal(bosh.Properties{"global": "value"}))
+			Expect(manifest.Tags).To(Equal(map[string]string{"product": "cf"}))
+			Expect(manifest.Variables[0].UpdateMode).To(Equal("converge"))
+		})
+
+		It("decodes the instance group fields", func() {
+			ig := manifest.MustFindInstanceGroupNamed("router")
+			Expect(ig.AZs).To(Equal([]string{"z1", "z2"}))
+			Expect(ig.Instances()).To(Equal(2))
+			Expect(ig.Lifecycle).To(Equal("service"))
+			Expect(ig.VMType).To(Equal("m3.medium"))
+			Expect(ig.VMExtensions).To(Equal([]string{"router-lb"}))
+			Expect(ig.Stemcell).To(Equal("default"))
+			Expect(ig.PersistentDiskType).To(Equal("10240"))
+			Expect(ig.Networks).To(Equal([]bosh.Network{{
+				Name:      "default",
+				StaticIPs: []string{"10.0.0.5", "10.0.0.6"},
+				Default:   []string{"dns", "gateway"},
+			}}))
+			Expect(ig.Update.MaxInFlight).To(Equal(1))
+			Expect(ig.MigratedFrom).To(Equal([]bosh.MigratedFrom{{Name: "router_z1", AZ: "z1"}}))
+			Expect(ig.Env.PersistentDiskFS).To(Equal("ext4"))
+			Expect(*ig.Env.Bosh.KeepRootPassword).To(BeTrue())
+			Expect(*ig.Env.Bosh.SwapSize).To(Equal(0))
+			Expect(ig.Env.Bosh.JobDir.TmpFS).To(BeTrue())
+		})
+
+		It("decodes the job fields", func() {
+			job := manifest.MustFindInstanceGroupNamed("router").MustFindJob("gorouter")
+			Expect(job.Release).To(Equal("routing"))
+			Expect(job.Provides).To(HaveKey("gorouter"))
+			Expect(job.CustomProviderDefinitions).To(Equal([]bosh.CustomProviderDefinition{{Name: "router-address", Type: "address"}}))
+			Expect(job.Properties().FindInt("router.port")).To(Equal(80))
+		})
+
+		It("decodes the addons", func() {
+			Expect(manifest.Addons).To(HaveLen(1))
+			addon := manifest.Addons[0]
+			Expect(addon.Name).To(Equal("syslog"))
+			Expect(addon.Jobs[0].Name()).To(Equal("syslog_forwarder"))
+			Expect(addon.Jobs[0].Release).To(Equal("syslog"))
+			Expect(addon.Include.Stemcell).To(Equal([]bosh.PlacementStemcell{{OS: "ubuntu-trusty"}}))
+			Expect(addon.Exclude.InstanceGroups).To(Equal([]string{"compilation"}))
+		})
+	})
+
 	Describe("InstanceGroups FindJobWithIndex", func() {
 		Context("when the InstanceGroup has a Jobs section", func() {
 			var instanceGroup *bosh.InstanceGroup
@@ -272,18 +425,113 @@ var _ = Describe("Manifest", func() {
 					Expect(err).ToNot(HaveOccurred())
 				})
 			})
-			Context("and an intermediate node is not of the expected type", func() {
-				It("panics", func() {
+			Context("and an intermediate node is another kind of map", func() {
+				It("returns the property value", func() {
 					p := &bosh.Properties{
 						"anotherProperty": "foo",
 						"an": map[string]string{
 							"unusual": "property",
 						},
+						"a": map[string]interface{}{
+							"json": map[interface{}]interface{}{
+								"decoded": []interface{}{
+									map[string]interface{}{"name": "first", "value": 1.5},
+								},
+							},
+						},
+					}
+					Expect(p.Find("an.unusual")).To(Equal("property"))
+					Expect(p.Find("a.json.decoded[name=first].value")).To(Equal(1.5))
+				})
+			})
+			Context("and an intermediate node is not a map", func() {
+				It("returns an error", func() {
+					p := &bosh.Properties{
+						"an": map[string]string{
+							"unusual": "property",
+						},
 					}
-					Expect(func() { p.Find("an.unusual.property") }).To(Panic())
+					v, err := p.Find("an.unusual.property")
+					Expect(v).To(BeNil())
+					Expect(err).To(MatchError(`value not a map at segment "property" of lens "an.unusual.property"`))
 				})
 			})
 		})
+		Context("when the lens indexes into a list", func() {
+			var p bosh.Properties
+
+			BeforeEach(func() {
+				p = bosh.Properties{
+					"uaa": bosh.Properties{
+						"clients": bosh.Properties{
+							"foo": bosh.Properties{
+								"scope": []interface{}{"openid", "uaa.admin"},
+							},
+						},
+					},
+					"router": bosh.Properties{
+						"routes": []interface{}{
+							bosh.Properties{"name": "api", "port": 9022},
+							bosh.Properties{"name": "uaa", "port": 8080},
+						},
+					},
+					"ssl.enabled": true,
+					"quoted": bosh.Properties{
+						"with\"quote": "value",
+					},
+				}
+			})
+
+			It("returns the element at a numeric index", func() {
+				Expect(p.Find("uaa.clients.foo.scope[0]")).To(Equal("openid"))
+				Expect(p.Find("uaa.clients.foo.scope[-1]")).To(Equal("uaa.admin"))
+			})
+
+			It("returns the element matching a selector", func() {
+				Expect(p.Find("router.routes[name=uaa].port")).To(Equal(8080))
+				Expect(p.Find("router.routes[port=9022]")).To(Equal(bosh.Properties{"name": "api", "port": 9022}))
+			})
+
+			It("supports quoted and escaped keys containing dots", func() {
+				Expect(p.Find(`"ssl.enabled"`)).To(BeTrue())
+				Expect(p.Find(`ssl\.enabled`)).To(BeTrue())
+				Expect(p.Find(`quoted."with\"quote"`)).To(Equal("value"))
+			})
+
+			It("describes the segment that could not be resolved", func() {
+				_, err := p.Find("router.routes[name=tcp].port")
+				Expect(err).To(MatchError(`no element matches at segment "[name=tcp]" of lens "router.routes[name=tcp].port"`))
+
+				_, err = p.Find("uaa.clients.foo.scope[2]")
+				Expect(err).To(MatchError(`index out of range at segment "[2]" of lens "uaa.clients.foo.scope[2]"`))
+
+				_, err = p.Find("router[0]")
+				Expect(err).To(MatchError(`value not a list at segment "[0]" of lens "router[0]"`))
+
+				Expect(bosh.ValidateLens("uaa.clients.bar.scope")).To(Succeed())
+
+				_, err = p.Find("uaa.clients.bar.scope")
+				lensErr, ok := err.(*bosh.LensError)
+				Expect(ok).To(BeTrue())
+				Expect(lensErr.Segment).To(Equal("bar"))
+				Expect(lensErr.Reason).To(Equal("value not found"))
+			})
+
+			DescribeTable("rejects malformed lenses",
+				func(lens, message string) {
+					_, err := p.Find(lens)
+					Expect(err).To(MatchError(message))
+					Expect(bosh.ValidateLens(lens)).To(MatchError(message))
+				},
+				Entry("empty", "", `empty lens at segment "" of lens ""`),
+				Entry("empty segment", "router..routes", `empty segment at segment "router." of lens "router..routes"`),
+				Entry("trailing dot", "router.", `empty segment at segment "router." of lens "router."`),
+				Entry("unterminated bracket", "router.routes[0", `unterminated bracket at segment "[0" of lens "router.routes[0"`),
+				Entry("invalid index", "router.routes[first]", `invalid index at segment "[first]" of lens "router.routes[first]"`),
+				Entry("unterminated quote", `"ssl.enabled`, `unterminated quote at segment "\"ssl.enabled" of lens "\"ssl.enabled"`),
+				Entry("junk after a quote", `"ssl"enabled`, `expected '.' or '[' at segment "\"ssl\"enabled" of lens "\"ssl\"enabled"`),
+			)
+		})
 		Context("when the value is an array", func() {
 			It("returns the property value", func() {
 				p := &bosh.Properties{
@@ -0,0 +1,217 @@
+package bosh
+
+import (
+	"errors"
+	"fmt"
+)
+
+type MergeStrategy int
+
+const (
+	// MergeOverwrite lets the values being merged in win over existing ones.
+	MergeOverwrite MergeStrategy = iota
+	// MergeKeepExisting only fills in values that are not already set, the
+	// way BOSH layers lower precedence properties underneath.
+	MergeKeepExisting
+)
+
+// Set stores value at the lens, creating any missing intermediate maps. List
+// elements addressed by an index or selector must already exist.
+func (p Properties) Set(lens string, value interface{}) error {
+	if p == nil {
+		return errors.New("cannot set a value on nil Properties")
+	}
+
+	segments, err := parseLens(lens)
+	if err != nil {
+		return err
+	}
+
+	_, err = setIn(p, segments, value, lens)
+	return err
+}
+
+// Delete removes the value at the lens. Deleting a list element shifts the
+// elements after it.
+func (p Properties) Delete(lens string) error {
+	segments, err := parseLens(lens)
+	if err != nil {
+		return err
+	}
+
+	_, err = deleteIn(p, segments, lens)
+	return err
+}
+
+// Merge deep merges other into p. Maps are merged key by key while lists and
+// scalars are replaced as a whole, according to strategy. Values taken from
+// other are copied, so later changes to either side are not shared.
+func (p Properties) Merge(other Properties, strategy MergeStrategy) {
+	merge(p, other, strategy)
+}
+
+func merge(dst, src interface{}, strategy MergeStrategy) {
+	srcProps, err := ToProperties(src)
+	if err != nil {
+		return
+	}
+
+	for k, srcValue := range srcProps {
+		key := fmt.Sprint(k)
+		dstValue, found, _ := MapValue(dst, key)
+
+		_, _, dstIsMap := MapValue(dstValue, "")
+		_, _, srcIsMap := MapValue(srcValue, "")
+		if found && dstIsMap && srcIsMap {
+			merge(dstValue, srcValue, strategy)
+			continue
+		}
+
+		if found && dstValue != nil && strategy == MergeKeepExisting {
+			continue
+		}
+
+		value := CopyValue(srcValue)
+		if !found && setOriginalKey(dst, k, value) {
+			continue
+		}
+		SetMapValue(dst, key, value)
+	}
+}
+
+// setOriginalKey stores value under k in maps that can hold keys other than
+// strings, so that merging "80: http" into a map without that key keeps 80 an
+// integer.
+func setOriginalKey(dst interface{}, k, value interface{}) bool {
+	switch m := dst.(type) {
+	case Properties:
+		m[k] = value
+		return true
+	case map[interface{}]interface{}:
+		m[k] = value
+		return true
+	}
+	return false
+}
+
+// setIn returns node with value stored at segments, so callers can store the
+// result back when node had to be created.
+func setIn(node interface{}, segments []lensSegment, value interface{}, lens string) (interface{}, error) {
+	if len(segments) == 0 {
+		return value, nil
+	}
+
+	s, rest := segments[0], segments[1:]
+	fail := func(reason string, err error) (interface{}, error) {
+		return nil, &LensError{Lens: lens, Segment: s.text, Reason: reason, Err: err}
+	}
+
+	switch s.kind {
+	case keySegment:
+		child, found, ok := MapValue(node, s.key)
+		if !ok {
+			return fail("value not a map", &TypeMismatchError{Path: lens, Expected: "a map", Actual: TypeName(node)})
+		}
+		if !found || (child == nil && len(rest) > 0) {
+			if len(rest) > 0 && rest[0].kind != keySegment {
+				return fail("value not found", ErrPropertyNotFound)
+			}
+			child = Properties{}
+		}
+
+		child, err := setIn(child, rest, value, lens)
+		if err != nil {
+			return nil, err
+		}
+		node, ok = SetMapValue(node, s.key, child)
+		if !ok {
+			return fail("map cannot hold the value", &TypeMismatchError{Path: lens, Expected: TypeName(node), Actual: TypeName(child)})
+		}
+		return node, nil
+	default:
+		list, ok := node.([]interface{})
+		if !ok {
+			return fail("value not a list", &TypeMismatchError{Path: lens, Expected: "a list", Actual: TypeName(node)})
+		}
+
+		index, found := elementIndex(list, s)
+		if !found {
+			return fail("value not found", ErrPropertyNotFound)
+		}
+
+		child, err := setIn(list[index], rest, value, lens)
+		if err != nil {
+			return nil, err
+		}
+		list[index] = child
+		return list, nil
+	}
+}
+
+func deleteIn(node interface{}, segments []lensSegment, lens string) (interface{}, error) {
+	s, rest := segments[0], segments[1:]
+	fail := func(reason string, err error) (interface{}, error) {
+		return nil, &LensError{Lens: lens, Segment: s.text, Reason: reason, Err: err}
+	}
+
+	switch s.kind {
+	case keySegment:
+		child, found, ok := MapValue(node, s.key)
+		if !ok {
+			return fail("value not a map", &TypeMismatchError{Path: lens, Expected: "a map", Actual: TypeName(node)})
+		}
+		if !found {
+			return fail("value not found", ErrPropertyNotFound)
+		}
+
+		if len(rest) == 0 {
+			return DeleteMapValue(node, s.key), nil
+		}
+
+		child, err := deleteIn(child, rest, lens)
+		if err != nil {
+			return nil, err
+		}
+		node, _ = SetMapValue(node, s.key, child)
+		return node, nil
+	default:
+		list, ok := node.([]interface{})
+		if !ok {
+			return fail("value not a list", &TypeMismatchError{Path: lens, Expected: "a list", Actual: TypeName(node)})
+		}
+
+		index, found := elementIndex(list, s)
+		if !found {
+			return fail("value not found", ErrPropertyNotFound)
+		}
+
+		if len(rest) == 0 {
+			return append(list[:index:index], list[index+1:]...), nil
+		}
+
+		child, err := deleteIn(list[index], rest, lens)
+		if err != nil {
+			return nil, err
+		}
+		list[index] = child
+		return list, nil
+	}
+}
+
+// elementIndex resolves an index or selector segment against a list.
+func elementIndex(list []interface{}, s lensSegment) (int, bool) {
+	if s.kind == indexSegment {
+		index := s.index
+		if index < 0 {
+			index += len(list)
+		}
+		return index, index >= 0 && index < len(list)
+	}
+
+	for i, element := range list {
+		if v, found, _ := MapValue(element, s.key); found && fmt.Sprint(v) == s.value {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,137 @@
+package bosh_test
+
+import (
+	"errors"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Properties mutation", func() {
+	var p bosh.Properties
+
+	BeforeEach(func() {
+		p = bosh.Properties{
+			"router": bosh.Properties{
+				"port": 80,
+			},
+			"route_services": map[string]interface{}{
+				"secret": "s3cr3t",
+			},
+			"backends": []interface{}{
+				bosh.Properties{"name": "a", "port": 8080},
+				bosh.Properties{"name": "b", "port": 8081},
+			},
+		}
+	})
+
+	Describe("Set", func() {
+		It("replaces existing values", func() {
+			Expect(p.Set("router.port", 443)).To(Succeed())
+			Expect(p.FindInt("router.port")).To(Equal(443))
+		})
+
+		It("creates missing intermediate maps", func() {
+			Expect(p.Set("router.tls.enabled", true)).To(Succeed())
+			Expect(p.FindBool("router.tls.enabled")).To(BeTrue())
+		})
+
+		It("sets values inside maps of other types", func() {
+			Expect(p.Set("route_services.secret", "rotated")).To(Succeed())
+			Expect(p.FindString("route_services.secret")).To(Equal("rotated"))
+		})
+
+		It("sets values inside list elements", func() {
+			Expect(p.Set("backends[name=b].port", 9000)).To(Succeed())
+			Expect(p.Set("backends[0].tls", true)).To(Succeed())
+
+			Expect(p.FindInt("backends[1].port")).To(Equal(9000))
+			Expect(p.FindBool("backends[name=a].tls")).To(BeTrue())
+		})
+
+		It("does not create list elements", func() {
+			err := p.Set("backends[name=c].port", 9000)
+			Expect(errors.Is(err, bosh.ErrPropertyNotFound)).To(BeTrue())
+		})
+
+		It("does not overwrite scalars on the way", func() {
+			err := p.Set("router.port.number", 443)
+			Expect(errors.Is(err, bosh.ErrTypeMismatch)).To(BeTrue())
+			Expect(p.FindInt("router.port")).To(Equal(80))
+		})
+
+		It("returns an error for nil Properties", func() {
+			var empty bosh.Properties
+			Expect(empty.Set("a", 1)).NotTo(Succeed())
+		})
+	})
+
+	Describe("Delete", func() {
+		It("removes map keys", func() {
+			Expect(p.Delete("router.port")).To(Succeed())
+			_, err := p.Lookup("router.port")
+			Expect(errors.Is(err, bosh.ErrPropertyNotFound)).To(BeTrue())
+			Expect(p.Find("router")).To(BeEmpty())
+		})
+
+		It("removes list elements", func() {
+			Expect(p.Delete("backends[name=a]")).To(Succeed())
+			Expect(p.FindString("backends[0].name")).To(Equal("b"))
+			Expect(p.Find("backends")).To(HaveLen(1))
+		})
+
+		It("returns an error when the value is missing", func() {
+			err := p.Delete("router.tls")
+			Expect(errors.Is(err, bosh.ErrPropertyNotFound)).To(BeTrue())
+		})
+	})
+
+	Describe("Merge", func() {
+		var other bosh.Properties
+
+		BeforeEach(func() {
+			other = bosh.Properties{
+				"router": map[interface{}]interface{}{
+					"port":    443,
+					"logging": "debug",
+				},
+				"backends": []interface{}{"c"},
+			}
+		})
+
+		It("lets the other values win with MergeOverwrite", func() {
+			p.Merge(other, bosh.MergeOverwrite)
+
+			Expect(p.FindInt("router.port")).To(Equal(443))
+			Expect(p.FindString("router.logging")).To(Equal("debug"))
+			Expect(p.FindStringSlice("backends")).To(Equal([]string{"c"}))
+			Expect(p.FindString("route_services.secret")).To(Equal("s3cr3t"))
+		})
+
+		It("only fills gaps with MergeKeepExisting", func() {
+			p.Merge(other, bosh.MergeKeepExisting)
+
+			Expect(p.FindInt("router.port")).To(Equal(80))
+			Expect(p.FindString("router.logging")).To(Equal("debug"))
+			Expect(p.FindString("backends[0].name")).To(Equal("a"))
+		})
+
+		It("does not share values with the other properties", func() {
+			p.Merge(bosh.Properties{"tls": bosh.Properties{"enabled": true}}, bosh.MergeOverwrite)
+			other.Merge(p, bosh.MergeOverwrite)
+
+			Expect(other.Set("tls.enabled", false)).To(Succeed())
+			Expect(p.FindBool("tls.enabled")).To(BeTrue())
+		})
+
+		It("keeps keys that are not strings", func() {
+			p.Merge(bosh.Properties{
+				"router": map[interface{}]interface{}{8080: "alt", "port": 443},
+			}, bosh.MergeOverwrite)
+
+			Expect(p["router"]).To(Equal(bosh.Properties{"port": 443, 8080: "alt"}))
+		})
+	})
+})
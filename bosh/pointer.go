@@ -0,0 +1,457 @@
+package bosh
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Pointer is a path into a manifest in the syntax of BOSH go-patch ops files,
+// e.g. /instance_groups/name=router/jobs/name=gorouter/properties/router?/port.
+//
+// A token ending in ? is optional, as are all of the tokens following it. The
+// :before, :after, :prev and :next modifiers are not supported.
+type Pointer struct {
+	Tokens []Token
+}
+
+type Token interface {
+	String() string
+}
+
+type KeyToken struct {
+	Key      string
+	Optional bool
+}
+
+type IndexToken struct {
+	Index int
+}
+
+type AfterLastIndexToken struct{}
+
+type MatchingIndexToken struct {
+	Key      string
+	Value    string
+	Optional bool
+}
+
+func (t KeyToken) String() string {
+	return escapePointerToken(t.Key) + optionalSuffix(t.Optional)
+}
+
+func (t IndexToken) String() string {
+	return strconv.Itoa(t.Index)
+}
+
+func (t AfterLastIndexToken) String() string {
+	return "-"
+}
+
+func (t MatchingIndexToken) String() string {
+	return escapePointerToken(t.Key) + "=" + escapePointerToken(t.Value) + optionalSuffix(t.Optional)
+}
+
+func optionalSuffix(optional bool) string {
+	if optional {
+		return "?"
+	}
+	return ""
+}
+
+var (
+	pointerTokenEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	pointerTokenUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+func escapePointerToken(s string) string {
+	return pointerTokenEscaper.Replace(s)
+}
+
+// PointerError describes which token of a pointer could not be parsed or
+// resolved.
+type PointerError struct {
+	Path   string
+	Token  string
+	Reason string
+}
+
+func (e *PointerError) Error() string {
+	return fmt.Sprintf("%s at token %q of path %q", e.Reason, e.Token, e.Path)
+}
+
+func ParsePointer(path string) (Pointer, error) {
+	if path == "" || path[0] != '/' {
+		return Pointer{}, &PointerError{Path: path, Reason: "path must start with /"}
+	}
+
+	if path == "/" {
+		return Pointer{}, nil
+	}
+
+	var (
+		tokens   []Token
+		optional bool
+	)
+	for _, raw := range strings.Split(path[1:], "/") {
+		tok := raw
+		if strings.HasSuffix(tok, "?") {
+			tok = tok[:len(tok)-1]
+			optional = true
+		}
+
+		if tok == "" {
+			return Pointer{}, &PointerError{Path: path, Token: raw, Reason: "empty token"}
+		}
+
+		if tok == "-" {
+			tokens = append(tokens, AfterLastIndexToken{})
+			continue
+		}
+
+		if index, err := strconv.Atoi(tok); err == nil {
+			tokens = append(tokens, IndexToken{Index: index})
+			continue
+		}
+
+		if eq := strings.IndexByte(tok, '='); eq > 0 {
+			tokens = append(tokens, MatchingIndexToken{
+				Key:      pointerTokenUnescaper.Replace(tok[:eq]),
+				Value:    pointerTokenUnescaper.Replace(tok[eq+1:]),
+				Optional: optional,
+			})
+			continue
+		}
+
+		tokens = append(tokens, KeyToken{Key: pointerTokenUnescaper.Replace(tok), Optional: optional})
+	}
+
+	return Pointer{Tokens: tokens}, nil
+}
+
+func (p Pointer) String() string {
+	if len(p.Tokens) == 0 {
+		return "/"
+	}
+
+	parts := make([]string, len(p.Tokens))
+	for i, t := range p.Tokens {
+		parts[i] = t.String()
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// Find resolves the pointer against a document made of maps and lists, as
+// produced by yaml.Unmarshal or json.Unmarshal. When an optional token is
+// missing Find returns found as false rather than an error.
+func (p Pointer) Find(doc interface{}) (value interface{}, found bool, err error) {
+	current := doc
+	for _, t := range p.Tokens {
+		fail := func(reason string, args ...interface{}) (interface{}, bool, error) {
+			return nil, false, &PointerError{Path: p.String(), Token: t.String(), Reason: fmt.Sprintf(reason, args...)}
+		}
+
+		switch token := t.(type) {
+		case KeyToken:
+			next, present, ok := MapValue(current, token.Key)
+			if !ok {
+				return fail("expected a map but found %s", TypeName(current))
+			}
+			if !present {
+				if token.Optional {
+					return nil, false, nil
+				}
+				return fail("missing key")
+			}
+			current = next
+		case IndexToken:
+			list, ok := listElements(current)
+			if !ok {
+				return fail("expected a list but found %s", TypeName(current))
+			}
+			index, ok := token.Resolve(len(list))
+			if !ok {
+				return fail("index out of range (list has %d elements)", len(list))
+			}
+			current = list[index]
+		case MatchingIndexToken:
+			list, ok := listElements(current)
+			if !ok {
+				return fail("expected a list but found %s", TypeName(current))
+			}
+			matches := token.Indices(list)
+			switch len(matches) {
+			case 0:
+				if token.Optional {
+					return nil, false, nil
+				}
+				return fail("no element matches")
+			case 1:
+				current = list[matches[0]]
+			default:
+				return fail("expected one element to match but found %d", len(matches))
+			}
+		case AfterLastIndexToken:
+			return fail("cannot find the element after the last one")
+		}
+	}
+
+	return current, true, nil
+}
+
+// Resolve returns the position the token refers to in a list of the given
+// length. Negative indices count from the end.
+func (t IndexToken) Resolve(length int) (int, bool) {
+	index := t.Index
+	if index < 0 {
+		index += length
+	}
+	return index, index >= 0 && index < length
+}
+
+// Indices returns the positions of the elements of list whose Key is Value.
+func (t MatchingIndexToken) Indices(list []interface{}) []int {
+	var matches []int
+	for i, element := range list {
+		if v, present, _ := MapValue(element, t.Key); present && fmt.Sprint(v) == t.Value {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+// TypeName names the dynamic type of v for error messages.
+func TypeName(v interface{}) string {
+	if v == nil {
+		return "nil"
+	}
+	return reflect.TypeOf(v).String()
+}
+
+// Get returns the value at a go-patch path such as
+// /instance_groups/name=router/jobs/name=gorouter/properties/router/port.
+// Maps in the returned value are Properties, so Find can be used on them. A
+// path whose optional tokens are missing resolves to nil.
+func (m *Manifest) Get(path string) (interface{}, error) {
+	p, err := ParsePointer(path)
+	if err != nil {
+		return nil, err
+	}
+
+	value, _, err := m.find(p)
+	return value, err
+}
+
+// Exists reports whether a go-patch path resolves to a value.
+func (m *Manifest) Exists(path string) bool {
+	p, err := ParsePointer(path)
+	if err != nil {
+		return false
+	}
+
+	_, found, err := m.find(p)
+	return err == nil && found
+}
+
+// find resolves the pointer on the struct fields and properties of the
+// manifest when it can. Everything else, including every failure, is left to
+// the generic document, which renders the whole manifest.
+func (m *Manifest) find(p Pointer) (interface{}, bool, error) {
+	if value, ok := findDirect(reflect.ValueOf(m), p.Tokens); ok {
+		return value, true, nil
+	}
+
+	doc, err := m.document()
+	if err != nil {
+		return nil, false, err
+	}
+
+	return p.Find(doc)
+}
+
+// findDirect walks structs by their yaml field names and lists of structs by
+// index or by field value. Once it reaches a map or an untyped value the rest
+// of the pointer is resolved with Pointer.Find. It gives up whenever the
+// generic document could differ: on keys the structs do not model, on zero
+// fields, which the document may omit, and on results that are structs.
+func findDirect(current reflect.Value, tokens []Token) (interface{}, bool) {
+	for i, t := range tokens {
+		for current.Kind() == reflect.Ptr || current.Kind() == reflect.Interface {
+			if current.IsNil() {
+				return nil, false
+			}
+			if current.Kind() == reflect.Interface {
+				return findData(current.Interface(), tokens[i:])
+			}
+			current = current.Elem()
+		}
+
+		switch current.Kind() {
+		case reflect.Map:
+			return findData(current.Interface(), tokens[i:])
+		case reflect.Struct:
+			key, ok := t.(KeyToken)
+			if !ok {
+				return nil, false
+			}
+			field, ok := structField(current, key.Key)
+			if !ok || field.IsZero() {
+				return nil, false
+			}
+			current = field
+		case reflect.Slice:
+			switch token := t.(type) {
+			case IndexToken:
+				index, ok := token.Resolve(current.Len())
+				if !ok {
+					return nil, false
+				}
+				current = current.Index(index)
+			case MatchingIndexToken:
+				element, ok := matchingElementValue(current, token)
+				if !ok {
+					return nil, false
+				}
+				current = element
+			default:
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+	}
+
+	return generic(current.Interface())
+}
+
+func findData(v interface{}, tokens []Token) (interface{}, bool) {
+	value, found, err := Pointer{Tokens: tokens}.Find(v)
+	if err != nil || !found {
+		return nil, false
+	}
+	return generic(value)
+}
+
+// matchingElementValue returns the only struct in the list whose field
+// matches the token.
+func matchingElementValue(list reflect.Value, token MatchingIndexToken) (reflect.Value, bool) {
+	if token.Value == "" {
+		return reflect.Value{}, false
+	}
+
+	var match reflect.Value
+	for i := 0; i < list.Len(); i++ {
+		element := reflect.Indirect(list.Index(i))
+		if element.Kind() != reflect.Struct {
+			return reflect.Value{}, false
+		}
+
+		field, ok := structField(element, token.Key)
+		if !ok {
+			return reflect.Value{}, false
+		}
+		if fmt.Sprint(field.Interface()) != token.Value {
+			continue
+		}
+
+		if match.IsValid() {
+			return reflect.Value{}, false
+		}
+		match = list.Index(i)
+	}
+
+	return match, match.IsValid()
+}
+
+var structFields sync.Map // reflect.Type to map[string]int
+
+// structField returns the field of a struct with the given yaml key.
+func structField(v reflect.Value, key string) (reflect.Value, bool) {
+	fields, cached := structFields.Load(v.Type())
+	if !cached {
+		indices := map[string]int{}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if tag == "-" {
+				continue
+			}
+			if tag == "" {
+				tag = strings.ToLower(field.Name)
+			}
+			indices[tag] = i
+		}
+		fields, _ = structFields.LoadOrStore(v.Type(), indices)
+	}
+
+	i, ok := fields.(map[string]int)[key]
+	if !ok {
+		return reflect.Value{}, false
+	}
+	return v.Field(i), true
+}
+
+// generic copies v in the shape the generic document would hold it in: maps
+// as Properties and lists as []interface{}. It reports false
+// for values that marshalling would change, such as structs and floats.
+func generic(v interface{}) (interface{}, bool) {
+	switch value := v.(type) {
+	case nil, string, bool, int:
+		return value, true
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		out := make(Properties, rv.Len())
+		for _, k := range rv.MapKeys() {
+			key, ok := generic(k.Interface())
+			if !ok {
+				return nil, false
+			}
+			e, ok := generic(rv.MapIndex(k).Interface())
+			if !ok {
+				return nil, false
+			}
+			out[key] = e
+		}
+		return out, true
+	case reflect.Slice:
+		if rv.IsNil() {
+			return nil, false
+		}
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			e, ok := generic(rv.Index(i).Interface())
+			if !ok {
+				return nil, false
+			}
+			out[i] = e
+		}
+		return out, true
+	}
+
+	return nil, false
+}
+
+// document renders the manifest as generic YAML data.
+func (m *Manifest) document() (Properties, error) {
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := Properties{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
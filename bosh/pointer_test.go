@@ -0,0 +1,123 @@
+package bosh_test
+
+import (
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pointer", func() {
+	Describe("ParsePointer", func() {
+		It("parses every kind of token", func() {
+			p, err := bosh.ParsePointer("/instance_groups/name=router/jobs/0/properties?/a~1b~0c/-")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p.Tokens).To(Equal([]bosh.Token{
+				bosh.KeyToken{Key: "instance_groups"},
+				bosh.MatchingIndexToken{Key: "name", Value: "router"},
+				bosh.KeyToken{Key: "jobs"},
+				bosh.IndexToken{Index: 0},
+				bosh.KeyToken{Key: "properties", Optional: true},
+				bosh.KeyToken{Key: "a/b~c", Optional: true},
+				bosh.AfterLastIndexToken{},
+			}))
+			Expect(p.String()).To(Equal("/instance_groups/name=router/jobs/0/properties?/a~1b~0c?/-"))
+		})
+
+		It("parses the root", func() {
+			p, err := bosh.ParsePointer("/")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p.Tokens).To(BeEmpty())
+		})
+
+		It("rejects relative and empty tokens", func() {
+			_, err := bosh.ParsePointer("instance_groups")
+			Expect(err).To(MatchError(`path must start with / at token "" of path "instance_groups"`))
+
+			_, err = bosh.ParsePointer("/instance_groups//jobs")
+			Expect(err).To(MatchError(`empty token at token "" of path "/instance_groups//jobs"`))
+		})
+	})
+
+	Describe("Manifest Get and Exists", func() {
+		var manifest *bosh.Manifest
+
+		BeforeEach(func() {
+			var err error
+			manifest, err = bosh.ParseManifest([]byte(`---
+name: cf
+instance_groups:
+- name: router
+  instances: 2
+  jobs:
+  - name: gorouter
+    properties:
+      router:
+        port: 80
+  - name: metron_agent
+    properties: {}
+- name: diego_cell
+  instances: 3
+  jobs:
+  - name: rep
+    properties: {}
+`))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		DescribeTable("resolves paths",
+			func(path string, expected interface{}) {
+				Expect(manifest.Get(path)).To(Equal(expected))
+				Expect(manifest.Exists(path)).To(BeTrue())
+			},
+			Entry("a top level key", "/name", "cf"),
+			Entry("a name selector", "/instance_groups/name=diego_cell/instances", 3),
+			Entry("an index", "/instance_groups/0/jobs/1/name", "metron_agent"),
+			Entry("a negative index", "/instance_groups/-1/name", "diego_cell"),
+			Entry("a property", "/instance_groups/name=router/jobs/name=gorouter/properties/router/port", 80),
+			Entry("a subtree", "/instance_groups/name=router/jobs/name=gorouter/properties", bosh.Properties{
+				"router": bosh.Properties{"port": 80},
+			}),
+		)
+
+		It("resolves missing optional tokens to nil", func() {
+			Expect(manifest.Get("/instance_groups/name=router/jobs/name=gorouter/properties/router/tls?/port")).To(BeNil())
+			Expect(manifest.Get("/instance_groups/name=uaa?/instances")).To(BeNil())
+			Expect(manifest.Exists("/instance_groups/name=uaa?/instances")).To(BeFalse())
+		})
+
+		DescribeTable("fails on paths that cannot be resolved",
+			func(path, message string) {
+				_, err := manifest.Get(path)
+				Expect(err).To(MatchError(message))
+				Expect(manifest.Exists(path)).To(BeFalse())
+			},
+			Entry("a missing key", "/instance_groups/name=router/azs", `missing key at token "azs" of path "/instance_groups/name=router/azs"`),
+			Entry("a missing element", "/instance_groups/name=uaa", `no element matches at token "name=uaa" of path "/instance_groups/name=uaa"`),
+			Entry("an index out of range", "/instance_groups/5", `index out of range (list has 2 elements) at token "5" of path "/instance_groups/5"`),
+			Entry("a key on a list", "/instance_groups/name", `expected a map but found []interface {} at token "name" of path "/instance_groups/name"`),
+			Entry("an index on a map", "/instance_groups/0/0", `expected a list but found bosh.Properties at token "0" of path "/instance_groups/0/0"`),
+			Entry("the element after the last", "/instance_groups/-", `cannot find the element after the last one at token "-" of path "/instance_groups/-"`),
+		)
+
+		It("sees changes made after an earlier Get", func() {
+			Expect(manifest.Get("/instance_groups/name=router/jobs/name=gorouter/properties/router/port")).To(Equal(80))
+
+			manifest.MustFindInstanceGroupNamed("router").MustFindJob("gorouter").Properties()["router"].(bosh.Properties)["port"] = 8080
+			manifest.MustFindInstanceGroupNamed("router").I = 4
+
+			Expect(manifest.Get("/instance_groups/name=router/jobs/name=gorouter/properties/router/port")).To(Equal(8080))
+			Expect(manifest.Get("/instance_groups/name=router/instances")).To(Equal(4))
+		})
+
+		It("returns copies of maps and lists", func() {
+			router, err := manifest.Get("/instance_groups/name=router/jobs/name=gorouter/properties/router")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(router).To(Equal(bosh.Properties{"port": 80}))
+
+			router.(bosh.Properties)["port"] = 443
+			Expect(manifest.Get("/instance_groups/name=router/jobs/name=gorouter/properties/router/port")).To(Equal(80))
+		})
+	})
+})
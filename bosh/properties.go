@@ -0,0 +1,312 @@
+package bosh
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FindIntLike is like FindInt but accepts every numeric kind YAML and JSON
+// decoders produce, as long as the value is a whole number that fits an int.
+func (p Properties) FindIntLike(lens string) (int, error) {
+	v, err := p.Find(lens)
+	if err != nil {
+		return 0, err
+	}
+	return toIntLike(lens, v)
+}
+
+func (p Properties) FindFloat(lens string) (float64, error) {
+	v, err := p.Find(lens)
+	if err != nil {
+		return 0, err
+	}
+	return toFloat(lens, v)
+}
+
+// FindDuration accepts strings in the format of time.ParseDuration, such as
+// "30s" or "1h30m", and plain numbers, which are taken to be seconds.
+func (p Properties) FindDuration(lens string) (time.Duration, error) {
+	v, err := p.Find(lens)
+	if err != nil {
+		return 0, err
+	}
+	return toDuration(lens, v)
+}
+
+func (p Properties) FindStringSlice(lens string) ([]string, error) {
+	v, err := p.Find(lens)
+	if err != nil {
+		return nil, err
+	}
+	return toStringSlice(lens, v)
+}
+
+// FindMap returns a map of any type with its keys converted to strings.
+func (p Properties) FindMap(lens string) (map[string]interface{}, error) {
+	v, err := p.Find(lens)
+	if err != nil {
+		return nil, err
+	}
+	return toMap(lens, v)
+}
+
+func (p Properties) FindProperties(lens string) (Properties, error) {
+	v, err := p.Find(lens)
+	if err != nil {
+		return nil, err
+	}
+
+	props, err := ToProperties(v)
+	if err != nil {
+		return nil, &TypeMismatchError{Path: lens, Expected: "a map", Actual: TypeName(v)}
+	}
+	return props, nil
+}
+
+// FindByteSize returns a size in bytes. Strings may carry a binary unit suffix
+// (B, K, KB, KiB, M, MB, MiB, G, GB, GiB, T, TB, TiB) and plain numbers are
+// taken to be bytes.
+func (p Properties) FindByteSize(lens string) (int64, error) {
+	v, err := p.Find(lens)
+	if err != nil {
+		return 0, err
+	}
+	return toByteSize(lens, v)
+}
+
+// The WithDefault variants return def when the property is missing or null,
+// as BOSH does when it renders a job template. A value of the wrong type is
+// still an error.
+
+func (p Properties) FindStringWithDefault(lens string, def string) (string, error) {
+	_, set, err := p.findSet(lens)
+	if err != nil || !set {
+		return def, err
+	}
+	return p.FindString(lens)
+}
+
+func (p Properties) FindIntWithDefault(lens string, def int) (int, error) {
+	_, set, err := p.findSet(lens)
+	if err != nil || !set {
+		return def, err
+	}
+	return p.FindInt(lens)
+}
+
+func (p Properties) FindBoolWithDefault(lens string, def bool) (bool, error) {
+	_, set, err := p.findSet(lens)
+	if err != nil || !set {
+		return def, err
+	}
+	return p.FindBool(lens)
+}
+
+func (p Properties) FindIntLikeWithDefault(lens string, def int) (int, error) {
+	v, set, err := p.findSet(lens)
+	if err != nil || !set {
+		return def, err
+	}
+	return toIntLike(lens, v)
+}
+
+func (p Properties) FindFloatWithDefault(lens string, def float64) (float64, error) {
+	v, set, err := p.findSet(lens)
+	if err != nil || !set {
+		return def, err
+	}
+	return toFloat(lens, v)
+}
+
+func (p Properties) FindDurationWithDefault(lens string, def time.Duration) (time.Duration, error) {
+	v, set, err := p.findSet(lens)
+	if err != nil || !set {
+		return def, err
+	}
+	return toDuration(lens, v)
+}
+
+func (p Properties) FindStringSliceWithDefault(lens string, def []string) ([]string, error) {
+	v, set, err := p.findSet(lens)
+	if err != nil || !set {
+		return def, err
+	}
+	return toStringSlice(lens, v)
+}
+
+func (p Properties) FindMapWithDefault(lens string, def map[string]interface{}) (map[string]interface{}, error) {
+	v, set, err := p.findSet(lens)
+	if err != nil || !set {
+		return def, err
+	}
+	return toMap(lens, v)
+}
+
+func (p Properties) FindPropertiesWithDefault(lens string, def Properties) (Properties, error) {
+	_, set, err := p.findSet(lens)
+	if err != nil || !set {
+		return def, err
+	}
+	return p.FindProperties(lens)
+}
+
+func (p Properties) FindByteSizeWithDefault(lens string, def int64) (int64, error) {
+	v, set, err := p.findSet(lens)
+	if err != nil || !set {
+		return def, err
+	}
+	return toByteSize(lens, v)
+}
+
+// findSet reports set as false, without an error, when the lens does not
+// resolve or resolves to null.
+func (p Properties) findSet(lens string) (interface{}, bool, error) {
+	v, err := p.Find(lens)
+	if errors.Is(err, ErrPropertyNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return v, v != nil, nil
+}
+
+func toIntLike(lens string, v interface{}) (int, error) {
+	mismatch := &TypeMismatchError{Path: lens, Expected: "an integer", Actual: TypeName(v)}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := rv.Int()
+		if i < math.MinInt || i > math.MaxInt {
+			return 0, mismatch
+		}
+		return int(i), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := rv.Uint()
+		if u > math.MaxInt {
+			return 0, mismatch
+		}
+		return int(u), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) || f < math.MinInt || f > math.MaxInt {
+			return 0, mismatch
+		}
+		return int(f), nil
+	}
+
+	return 0, mismatch
+}
+
+func toFloat(lens string, v interface{}) (float64, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+
+	return 0, &TypeMismatchError{Path: lens, Expected: "a number", Actual: TypeName(v)}
+}
+
+func toDuration(lens string, v interface{}) (time.Duration, error) {
+	if s, ok := v.(string); ok {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("value at %q: %s", lens, err)
+		}
+		return d, nil
+	}
+
+	seconds, err := toFloat(lens, v)
+	if err != nil {
+		return 0, &TypeMismatchError{Path: lens, Expected: "a duration", Actual: TypeName(v)}
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func toStringSlice(lens string, v interface{}) ([]string, error) {
+	mismatch := &TypeMismatchError{Path: lens, Expected: "a list of strings", Actual: TypeName(v)}
+
+	list, ok := listElements(v)
+	if !ok {
+		return nil, mismatch
+	}
+
+	strs := make([]string, len(list))
+	for i, e := range list {
+		s, ok := e.(string)
+		if !ok {
+			return nil, mismatch
+		}
+		strs[i] = s
+	}
+	return strs, nil
+}
+
+func toMap(lens string, v interface{}) (map[string]interface{}, error) {
+	props, err := ToProperties(v)
+	if err != nil {
+		return nil, &TypeMismatchError{Path: lens, Expected: "a map", Actual: TypeName(v)}
+	}
+
+	m := make(map[string]interface{}, len(props))
+	for k, e := range props {
+		m[fmt.Sprint(k)] = e
+	}
+	return m, nil
+}
+
+var byteSizeUnits = map[string]int64{
+	"":    1,
+	"B":   1,
+	"K":   1 << 10,
+	"KB":  1 << 10,
+	"KIB": 1 << 10,
+	"M":   1 << 20,
+	"MB":  1 << 20,
+	"MIB": 1 << 20,
+	"G":   1 << 30,
+	"GB":  1 << 30,
+	"GIB": 1 << 30,
+	"T":   1 << 40,
+	"TB":  1 << 40,
+	"TIB": 1 << 40,
+}
+
+func toByteSize(lens string, v interface{}) (int64, error) {
+	s, ok := v.(string)
+	if !ok {
+		n, err := toIntLike(lens, v)
+		if err != nil {
+			return 0, &TypeMismatchError{Path: lens, Expected: "a byte size", Actual: TypeName(v)}
+		}
+		return int64(n), nil
+	}
+
+	s = strings.TrimSpace(s)
+	split := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if split < 0 {
+		split = len(s)
+	}
+
+	number, unit := s[:split], strings.ToUpper(strings.TrimSpace(s[split:]))
+	multiplier, known := byteSizeUnits[unit]
+	size, err := strconv.ParseFloat(number, 64)
+	if !known || err != nil {
+		return 0, fmt.Errorf("value at %q: invalid byte size %q", lens, s)
+	}
+
+	return int64(size * float64(multiplier)), nil
+}
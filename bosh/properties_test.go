@@ -0,0 +1,133 @@
+package bosh_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Typed property accessors", func() {
+	var p bosh.Properties
+
+	BeforeEach(func() {
+		p = bosh.Properties{
+			"int":          42,
+			"int64":        int64(42),
+			"uint64":       uint64(42),
+			"float":        42.0,
+			"fraction":     1.5,
+			"string":       "forty-two",
+			"duration":     "1m30s",
+			"seconds":      90,
+			"strings":      []interface{}{"a", "b"},
+			"mixed":        []interface{}{"a", 1},
+			"typedStrings": []string{"c"},
+			"map":          bosh.Properties{"key": "value", 80: "http"},
+			"jsonMap":      map[string]interface{}{"key": "value"},
+			"size":         "10G",
+			"smallSize":    "512 MiB",
+			"bytes":        1024,
+			"badSize":      "10X",
+			"null":         nil,
+		}
+	})
+
+	DescribeTable("FindIntLike",
+		func(lens string, expected int) {
+			Expect(p.FindIntLike(lens)).To(Equal(expected))
+		},
+		Entry("int", "int", 42),
+		Entry("int64", "int64", 42),
+		Entry("uint64", "uint64", 42),
+		Entry("whole float", "float", 42),
+	)
+
+	It("FindIntLike rejects fractions and strings", func() {
+		_, err := p.FindIntLike("fraction")
+		Expect(errors.Is(err, bosh.ErrTypeMismatch)).To(BeTrue())
+
+		_, err = p.FindIntLike("string")
+		Expect(err).To(MatchError(`value at "string" is string, not an integer`))
+	})
+
+	It("FindFloat accepts every numeric kind", func() {
+		Expect(p.FindFloat("int")).To(Equal(42.0))
+		Expect(p.FindFloat("uint64")).To(Equal(42.0))
+		Expect(p.FindFloat("fraction")).To(Equal(1.5))
+
+		_, err := p.FindFloat("string")
+		Expect(errors.Is(err, bosh.ErrTypeMismatch)).To(BeTrue())
+	})
+
+	It("FindDuration accepts duration strings and seconds", func() {
+		Expect(p.FindDuration("duration")).To(Equal(90 * time.Second))
+		Expect(p.FindDuration("seconds")).To(Equal(90 * time.Second))
+		Expect(p.FindDuration("fraction")).To(Equal(1500 * time.Millisecond))
+
+		_, err := p.FindDuration("string")
+		Expect(err).To(MatchError(ContainSubstring(`value at "string": time: invalid duration`)))
+
+		_, err = p.FindDuration("strings")
+		Expect(errors.Is(err, bosh.ErrTypeMismatch)).To(BeTrue())
+	})
+
+	It("FindStringSlice accepts lists of strings", func() {
+		Expect(p.FindStringSlice("strings")).To(Equal([]string{"a", "b"}))
+		Expect(p.FindStringSlice("typedStrings")).To(Equal([]string{"c"}))
+
+		_, err := p.FindStringSlice("mixed")
+		Expect(err).To(MatchError(`value at "mixed" is []interface {}, not a list of strings`))
+	})
+
+	It("FindMap and FindProperties accept any map", func() {
+		Expect(p.FindMap("map")).To(Equal(map[string]interface{}{"key": "value", "80": "http"}))
+		Expect(p.FindMap("jsonMap")).To(Equal(map[string]interface{}{"key": "value"}))
+		Expect(p.FindProperties("jsonMap")).To(Equal(bosh.Properties{"key": "value"}))
+
+		_, err := p.FindProperties("string")
+		Expect(errors.Is(err, bosh.ErrTypeMismatch)).To(BeTrue())
+	})
+
+	It("FindByteSize accepts sizes with units and plain bytes", func() {
+		Expect(p.FindByteSize("size")).To(Equal(int64(10 << 30)))
+		Expect(p.FindByteSize("smallSize")).To(Equal(int64(512 << 20)))
+		Expect(p.FindByteSize("bytes")).To(Equal(int64(1024)))
+
+		_, err := p.FindByteSize("badSize")
+		Expect(err).To(MatchError(`value at "badSize": invalid byte size "10X"`))
+	})
+
+	Describe("WithDefault variants", func() {
+		It("return the default for missing and null properties", func() {
+			Expect(p.FindStringWithDefault("missing", "default")).To(Equal("default"))
+			Expect(p.FindIntWithDefault("null", 7)).To(Equal(7))
+			Expect(p.FindBoolWithDefault("map.missing", true)).To(BeTrue())
+			Expect(p.FindIntLikeWithDefault("missing", 7)).To(Equal(7))
+			Expect(p.FindFloatWithDefault("missing", 0.5)).To(Equal(0.5))
+			Expect(p.FindDurationWithDefault("missing", time.Minute)).To(Equal(time.Minute))
+			Expect(p.FindStringSliceWithDefault("missing", []string{"x"})).To(Equal([]string{"x"}))
+			Expect(p.FindMapWithDefault("missing", map[string]interface{}{})).To(BeEmpty())
+			Expect(p.FindPropertiesWithDefault("missing", bosh.Properties{"a": 1})).To(Equal(bosh.Properties{"a": 1}))
+			Expect(p.FindByteSizeWithDefault("missing", 1)).To(Equal(int64(1)))
+		})
+
+		It("return the value when it is set", func() {
+			Expect(p.FindStringWithDefault("string", "default")).To(Equal("forty-two"))
+			Expect(p.FindIntLikeWithDefault("int64", 7)).To(Equal(42))
+			Expect(p.FindByteSizeWithDefault("size", 1)).To(Equal(int64(10 << 30)))
+		})
+
+		It("still fail on values of the wrong type", func() {
+			_, err := p.FindIntWithDefault("string", 7)
+			Expect(errors.Is(err, bosh.ErrTypeMismatch)).To(BeTrue())
+
+			_, err = p.FindStringWithDefault("string.nested", "default")
+			Expect(errors.Is(err, bosh.ErrTypeMismatch)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,255 @@
+package bosh
+
+import (
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ParseManifest decodes a manifest, retaining any keys the manifest structs
+// do not model, at any depth, so that marshalling the result emits them again
+// in their original order. Manifests decoded with yaml.Unmarshal only hold
+// what the structs model.
+func ParseManifest(b []byte) (*Manifest, error) {
+	m := &Manifest{}
+	if err := yaml.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+
+	raw := yaml.MapSlice{}
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	m.raw = raw
+
+	return m, nil
+}
+
+type manifestFields Manifest
+
+func (m Manifest) MarshalYAML() (interface{}, error) {
+	return encodePreserving(manifestFields(m), m.raw)
+}
+
+// encodePreserving renders fields in the key order of raw, re-emitting the
+// keys of raw that the structs do not model, however deeply they are nested.
+func encodePreserving(fields interface{}, raw yaml.MapSlice) (interface{}, error) {
+	b, err := yaml.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	known := yaml.MapSlice{}
+	if err := yaml.Unmarshal(b, &known); err != nil {
+		return nil, err
+	}
+
+	if raw == nil {
+		return known, nil
+	}
+
+	return overlay(reflect.TypeOf(fields), known, raw), nil
+}
+
+// overlay lays the rendering v of a value of type t over its original node
+// tmpl. For structs, keys of tmpl that are not fields are kept in place and
+// known keys that were absent from tmpl are appended, unless they only hold
+// a zero value, so that decoding and encoding an unmodified document does not
+// introduce new keys. Lists of structs are matched element by element. Maps
+// and untyped values are data rather than schema, so v is authoritative and
+// only takes the key order of tmpl.
+func overlay(t reflect.Type, v, tmpl interface{}) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		value, ok := v.(yaml.MapSlice)
+		original, isMap := tmpl.(yaml.MapSlice)
+		if !ok || !isMap {
+			return v
+		}
+
+		fields := fieldTypes(t)
+		emitted := map[interface{}]bool{}
+
+		out := yaml.MapSlice{}
+		for _, item := range original {
+			name, ok := item.Key.(string)
+			fieldType, known := fields[name]
+			if !ok || !known {
+				out = append(out, item)
+				continue
+			}
+
+			if e, found := mapSliceValue(value, name); found {
+				out = append(out, yaml.MapItem{Key: name, Value: overlay(fieldType, e, item.Value)})
+				emitted[name] = true
+			}
+		}
+
+		for _, item := range value {
+			if emitted[item.Key] || isZeroValue(item.Value) {
+				continue
+			}
+			out = append(out, item)
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		list, ok := v.([]interface{})
+		original, isList := tmpl.([]interface{})
+		if !ok || !isList {
+			return v
+		}
+
+		out := make([]interface{}, len(list))
+		for i, counterpart := range Counterparts(list, original) {
+			out[i] = overlay(t.Elem(), list[i], counterpart)
+		}
+		return out
+	}
+
+	return reorder(v, tmpl)
+}
+
+// reorder returns v with the keys of every nested mapping arranged in the order
+// they appear in tmpl. Keys missing from tmpl keep their relative order and
+// follow the ones that were found.
+func reorder(v, tmpl interface{}) interface{} {
+	switch value := v.(type) {
+	case yaml.MapSlice:
+		t, ok := tmpl.(yaml.MapSlice)
+		if !ok {
+			return value
+		}
+
+		out := make(yaml.MapSlice, 0, len(value))
+		used := make([]bool, len(value))
+		for _, ti := range t {
+			for i, vi := range value {
+				if !used[i] && reflect.DeepEqual(vi.Key, ti.Key) {
+					out = append(out, yaml.MapItem{Key: vi.Key, Value: reorder(vi.Value, ti.Value)})
+					used[i] = true
+					break
+				}
+			}
+		}
+		for i, vi := range value {
+			if !used[i] {
+				out = append(out, vi)
+			}
+		}
+		return out
+	case []interface{}:
+		t, ok := tmpl.([]interface{})
+		if !ok {
+			return value
+		}
+
+		out := make([]interface{}, len(value))
+		for i, counterpart := range Counterparts(value, t) {
+			out[i] = reorder(value[i], counterpart)
+		}
+		return out
+	}
+
+	return v
+}
+
+// identityKeys identify the elements of manifest lists, in order of
+// preference: stemcells by alias and everything else by name.
+var identityKeys = []string{"alias", "name"}
+
+// Counterparts returns, for each element of a rendered manifest list, the
+// element of original that stands for the same entry, or nil for new
+// elements. Elements are matched by identity key. Elements without one are
+// matched by position, and only when no element was added or removed, so that
+// one element is never taken for another.
+func Counterparts(list, original []interface{}) []interface{} {
+	out := make([]interface{}, len(list))
+	for i, element := range list {
+		key, id, ok := identity(element)
+		if !ok {
+			if len(list) == len(original) {
+				if _, _, keyed := identity(original[i]); !keyed {
+					out[i] = original[i]
+				}
+			}
+			continue
+		}
+
+		for _, candidate := range original {
+			if k, other, ok := identity(candidate); ok && k == key && reflect.DeepEqual(id, other) {
+				out[i] = candidate
+				break
+			}
+		}
+	}
+	return out
+}
+
+// identity returns the first identity key a list element sets and its value.
+func identity(element interface{}) (string, interface{}, bool) {
+	m, ok := element.(yaml.MapSlice)
+	if !ok {
+		return "", nil, false
+	}
+
+	for _, key := range identityKeys {
+		if id, found := mapSliceValue(m, key); found && !isZeroValue(id) {
+			return key, id, true
+		}
+	}
+	return "", nil, false
+}
+
+func mapSliceValue(m yaml.MapSlice, key interface{}) (interface{}, bool) {
+	for _, item := range m {
+		if reflect.DeepEqual(item.Key, key) {
+			return item.Value, true
+		}
+	}
+	return nil, false
+}
+
+func isZeroValue(v interface{}) bool {
+	switch value := v.(type) {
+	case nil:
+		return true
+	case yaml.MapSlice:
+		return len(value) == 0
+	case []interface{}:
+		return len(value) == 0
+	case string:
+		return value == ""
+	case int:
+		return value == 0
+	case bool:
+		return !value
+	}
+	return false
+}
+
+// fieldTypes maps the yaml keys of the exported fields of a struct type to
+// their types.
+func fieldTypes(t reflect.Type) map[string]reflect.Type {
+	types := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+		if tag == "" {
+			tag = strings.ToLower(field.Name)
+		}
+		types[tag] = field.Type
+	}
+	return types
+}
@@ -0,0 +1,195 @@
+package bosh_test
+
+import (
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"gopkg.in/yaml.v2"
+)
+
+const stagedManifest = `name: cf-some-guid
+unknown_top_level: some-value
+releases:
+- name: routing
+  version: 0.166.0
+  unknown_release_key: x
+features:
+  use_dns_addresses: true
+  unknown_feature: true
+update:
+  canaries: 1
+  extra_update_key: x
+instance_groups:
+- name: router
+  instances: 2
+  unknown_instance_group_key:
+    nested: true
+  networks:
+  - name: default
+    unknown_network_key: x
+  env:
+    bosh:
+      extra_env: x
+      password: secret
+  jobs:
+  - name: gorouter
+    release: routing
+    properties:
+      router:
+        status:
+          user: admin
+          password: secret
+        port: 80
+        enable_ssl: true
+    unknown_job_key:
+    - a
+    - b
+    consumes:
+      nats:
+        from: nats
+  properties: {}
+  vm_type: m3.medium
+variables:
+- name: router_ca
+  type: certificate
+  unknown_variable_key: 1
+  options:
+    is_ca: true
+jobs: []
+`
+
+var _ = Describe("Round-tripping", func() {
+	var manifest *bosh.Manifest
+
+	BeforeEach(func() {
+		var err error
+		manifest, err = bosh.ParseManifest([]byte(stagedManifest))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("emits an unmodified manifest byte for byte", func() {
+		b, err := yaml.Marshal(manifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal(stagedManifest))
+	})
+
+	It("only remembers the document of parsed manifests", func() {
+		r := &bosh.StagedManifestResponse{}
+		Expect(yaml.Unmarshal([]byte("manifest:\n  unknown: key\n  name: cf\n"), r)).To(Succeed())
+		Expect(r.Manifest).To(Equal(&bosh.Manifest{Name: "cf"}))
+
+		b, err := yaml.Marshal(r)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("manifest:\n  name: cf\n  releases: []\n  jobs: []\n  instance_groups: []\n  variables: []\n"))
+	})
+
+	It("emits modified known fields in their original position", func() {
+		ig := manifest.MustFindInstanceGroupNamed("router")
+		ig.I = 3
+		ig.VMType = ""
+		ig.AZs = []string{"z1"}
+		ig.MustFindJob("gorouter").P["router"].(bosh.Properties)["port"] = 8080
+
+		b, err := yaml.Marshal(manifest)
+		Expect(err).NotTo(HaveOccurred())
+
+		roundTripped := yaml.MapSlice{}
+		Expect(yaml.Unmarshal(b, &roundTripped)).To(Succeed())
+
+		instanceGroup := roundTripped[5].Value.([]interface{})[0].(yaml.MapSlice)
+		Expect(instanceGroup).To(Equal(yaml.MapSlice{
+			{Key: "name", Value: "router"},
+			{Key: "instances", Value: 3},
+			{Key: "unknown_instance_group_key", Value: yaml.MapSlice{{Key: "nested", Value: true}}},
+			{Key: "networks", Value: []interface{}{yaml.MapSlice{{Key: "name", Value: "default"}, {Key: "unknown_network_key", Value: "x"}}}},
+			{Key: "env", Value: yaml.MapSlice{{Key: "bosh", Value: yaml.MapSlice{{Key: "extra_env", Value: "x"}, {Key: "password", Value: "secret"}}}}},
+			{Key: "jobs", Value: instanceGroup[5].Value},
+			{Key: "properties", Value: yaml.MapSlice(nil)},
+			{Key: "azs", Value: []interface{}{"z1"}},
+		}))
+
+		job := instanceGroup[5].Value.([]interface{})[0].(yaml.MapSlice)
+		Expect(job[2]).To(Equal(yaml.MapItem{Key: "properties", Value: yaml.MapSlice{
+			{Key: "router", Value: yaml.MapSlice{
+				{Key: "status", Value: yaml.MapSlice{
+					{Key: "user", Value: "admin"},
+					{Key: "password", Value: "secret"},
+				}},
+				{Key: "port", Value: 8080},
+				{Key: "enable_ssl", Value: true},
+			}},
+		}}))
+	})
+
+	It("keeps unknown keys of modified nested structs", func() {
+		manifest.Update.Canaries = 2
+		manifest.Releases[0].Version = "0.167.0"
+		manifest.MustFindInstanceGroupNamed("router").Env.Bosh.Password = ""
+
+		b, err := yaml.Marshal(manifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(ContainSubstring("releases:\n- name: routing\n  version: 0.167.0\n  unknown_release_key: x\n"))
+		Expect(string(b)).To(ContainSubstring("update:\n  canaries: 2\n  extra_update_key: x\n"))
+		Expect(string(b)).To(ContainSubstring("  env:\n    bosh:\n      extra_env: x\n  jobs:"))
+	})
+
+	It("keeps unknown keys with their element when elements are removed or reordered", func() {
+		m, err := bosh.ParseManifest([]byte(`stemcells:
+- alias: a
+  os: ubuntu-xenial
+  version: "1"
+  extra: A
+- alias: b
+  os: ubuntu-xenial
+  version: "2"
+  extra: B
+instance_groups:
+- name: router
+  extra: router
+- name: api
+  extra: api
+`))
+		Expect(err).NotTo(HaveOccurred())
+
+		m.Stemcells = m.Stemcells[1:]
+		m.InstanceGroups[0], m.InstanceGroups[1] = m.InstanceGroups[1], m.InstanceGroups[0]
+
+		b, err := yaml.Marshal(m)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal(`stemcells:
+- alias: b
+  os: ubuntu-xenial
+  version: "2"
+  extra: B
+instance_groups:
+- name: api
+  extra: api
+- name: router
+  extra: router
+`))
+	})
+
+	It("decodes jobs and instance groups equal to ones built in code", func() {
+		m, err := bosh.ParseManifest([]byte("instance_groups:\n- name: ig\n  unknown: x\n  jobs:\n  - name: job\n    unknown: x\n"))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(m.InstanceGroups[0]).To(Equal(bosh.NewInstanceGroup("ig", []*bosh.Job{bosh.NewJob("job")})))
+	})
+
+	It("marshals manifests that were built in code", func() {
+		m := &bosh.Manifest{
+			Name:           "built",
+			InstanceGroups: []*bosh.InstanceGroup{bosh.NewInstanceGroup("ig", []*bosh.Job{bosh.NewJob("job")})},
+		}
+
+		b, err := yaml.Marshal(m)
+		Expect(err).NotTo(HaveOccurred())
+
+		decoded, err := bosh.ParseManifest(b)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(decoded.Name).To(Equal("built"))
+		Expect(decoded.MustFindInstanceGroupNamed("ig").MustFindJob("job")).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,255 @@
+package bosh
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// MapValue looks key up in any kind of map: Properties, the
+// map[interface{}]interface{} produced by yaml.v2, the map[string]interface{}
+// produced by encoding/json or yaml.v3, yaml.MapSlice, and typed maps with
+// string keys. ok is false when v is not a map at all.
+func MapValue(v interface{}, key string) (value interface{}, found bool, ok bool) {
+	switch m := v.(type) {
+	case Properties:
+		value, found = interfaceMapValue(m, key)
+		return value, found, true
+	case map[interface{}]interface{}:
+		value, found = interfaceMapValue(m, key)
+		return value, found, true
+	case map[string]interface{}:
+		value, found = m[key]
+		return value, found, true
+	case yaml.MapSlice:
+		if i, found := mapSliceIndex(m, key); found {
+			return m[i].Value, true, true
+		}
+		return nil, false, true
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil, false, false
+	}
+
+	e := rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()))
+	if !e.IsValid() {
+		return nil, false, true
+	}
+	return e.Interface(), true, true
+}
+
+// interfaceMapValue prefers an exact string key but falls back to keys that
+// YAML decoded as other scalars, such as the integer key of "80: http".
+func interfaceMapValue(m map[interface{}]interface{}, key string) (interface{}, bool) {
+	if value, found := m[key]; found {
+		return value, true
+	}
+
+	for k, value := range m {
+		if _, isString := k.(string); !isString && fmt.Sprint(k) == key {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// listElements returns the elements of any kind of slice or array.
+func listElements(v interface{}) ([]interface{}, bool) {
+	if list, ok := v.([]interface{}); ok {
+		return list, true
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+
+	list := make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list, true
+}
+
+// ToProperties converts any kind of map, for instance the
+// map[string]interface{} of a JSON decoded manifest, into Properties so it
+// can be queried with Find. Nested values are left as they are; lookups
+// traverse them whatever their map type.
+func ToProperties(v interface{}) (Properties, error) {
+	if p, ok := v.(Properties); ok {
+		return p, nil
+	}
+
+	if _, _, ok := MapValue(v, ""); !ok {
+		return nil, &TypeMismatchError{Expected: "a map", Actual: TypeName(v)}
+	}
+
+	p := Properties{}
+	switch m := v.(type) {
+	case map[interface{}]interface{}:
+		for k, e := range m {
+			p[k] = e
+		}
+	case yaml.MapSlice:
+		for _, item := range m {
+			p[item.Key] = item.Value
+		}
+	default:
+		rv := reflect.ValueOf(v)
+		for _, k := range rv.MapKeys() {
+			p[k.Interface()] = rv.MapIndex(k).Interface()
+		}
+	}
+	return p, nil
+}
+
+// SetMapValue stores value under key in any kind of map, reusing an existing
+// key that stands for the same key, such as the integer key of "80: http". It
+// returns the map, which is a new one when a key had to be appended to a
+// yaml.MapSlice, and reports false when v is not a map that can hold value.
+func SetMapValue(v interface{}, key string, value interface{}) (interface{}, bool) {
+	switch m := v.(type) {
+	case Properties:
+		m[interfaceMapKey(m, key)] = value
+		return m, true
+	case map[interface{}]interface{}:
+		m[interfaceMapKey(m, key)] = value
+		return m, true
+	case map[string]interface{}:
+		m[key] = value
+		return m, true
+	case yaml.MapSlice:
+		if i, found := mapSliceIndex(m, key); found {
+			m[i].Value = value
+			return m, true
+		}
+		return append(m, yaml.MapItem{Key: key, Value: value}), true
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map || rv.IsNil() || rv.Type().Key().Kind() != reflect.String {
+		return v, false
+	}
+
+	ev := reflect.ValueOf(value)
+	if !ev.IsValid() {
+		ev = reflect.Zero(rv.Type().Elem())
+	}
+	if !ev.Type().AssignableTo(rv.Type().Elem()) {
+		return v, false
+	}
+	rv.SetMapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()), ev)
+	return v, true
+}
+
+// DeleteMapValue removes key from any kind of map and returns the map, which
+// is shorter when v is a yaml.MapSlice.
+func DeleteMapValue(v interface{}, key string) interface{} {
+	switch m := v.(type) {
+	case Properties:
+		delete(m, interfaceMapKey(m, key))
+		return m
+	case map[interface{}]interface{}:
+		delete(m, interfaceMapKey(m, key))
+		return m
+	case map[string]interface{}:
+		delete(m, key)
+		return m
+	case yaml.MapSlice:
+		if i, found := mapSliceIndex(m, key); found {
+			return append(m[:i], m[i+1:]...)
+		}
+		return m
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String {
+		rv.SetMapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()), reflect.Value{})
+	}
+	return v
+}
+
+func mapSliceIndex(m yaml.MapSlice, key string) (int, bool) {
+	for i, item := range m {
+		if item.Key == key || fmt.Sprint(item.Key) == key {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func interfaceMapKey(m map[interface{}]interface{}, key string) interface{} {
+	if _, found := m[key]; found {
+		return key
+	}
+	for k := range m {
+		if _, isString := k.(string); !isString && fmt.Sprint(k) == key {
+			return k
+		}
+	}
+	return key
+}
+
+// CopyValue deep copies maps, yaml.MapSlices and lists, keeping their types.
+func CopyValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case yaml.MapSlice:
+		out := make(yaml.MapSlice, len(value))
+		for i, item := range value {
+			out[i] = yaml.MapItem{Key: item.Key, Value: CopyValue(item.Value)}
+		}
+		return out
+	case Properties:
+		out := make(Properties, len(value))
+		for k, e := range value {
+			out[k] = CopyValue(e)
+		}
+		return out
+	case map[interface{}]interface{}:
+		out := make(map[interface{}]interface{}, len(value))
+		for k, e := range value {
+			out[k] = CopyValue(e)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for k, e := range value {
+			out[k] = CopyValue(e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(value))
+		for i, e := range value {
+			out[i] = CopyValue(e)
+		}
+		return out
+	}
+	return v
+}
+
+// Leaves returns the lens of every value that is not a map, in sorted order.
+// Lists and empty maps count as leaves.
+func (p Properties) Leaves() []string {
+	var leaves []string
+	walkLeaves(p, "", func(lens string) {
+		leaves = append(leaves, lens)
+	})
+	sort.Strings(leaves)
+	return leaves
+}
+
+func walkLeaves(v interface{}, path string, visit func(lens string)) {
+	props, err := ToProperties(v)
+	if err != nil || (len(props) == 0 && path != "") {
+		visit(path)
+		return
+	}
+
+	for k, e := range props {
+		walkLeaves(e, joinLens(path, fmt.Sprint(k)), visit)
+	}
+}
@@ -0,0 +1,81 @@
+package bosh_test
+
+import (
+	"encoding/json"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Traversing JSON decoded data", func() {
+	var properties bosh.Properties
+
+	BeforeEach(func() {
+		var decoded map[string]interface{}
+		Expect(json.Unmarshal([]byte(`{
+			"router": {
+				"port": 80,
+				"routes": [{"name": "api", "ports": [9022, 9023]}]
+			}
+		}`), &decoded)).To(Succeed())
+
+		var err error
+		properties, err = bosh.ToProperties(decoded)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("finds nested values", func() {
+		Expect(properties.Find("router.port")).To(Equal(float64(80)))
+		Expect(properties.Find("router.routes[name=api].ports[1]")).To(Equal(float64(9023)))
+	})
+
+	It("reports missing values", func() {
+		_, err := properties.Find("router.routes[name=uaa]")
+		Expect(err).To(MatchError(`no element matches at segment "[name=uaa]" of lens "router.routes[name=uaa]"`))
+	})
+
+	It("resolves go-patch pointers", func() {
+		p, err := bosh.ParsePointer("/router/routes/name=api/ports/0")
+		Expect(err).NotTo(HaveOccurred())
+
+		value, found, err := p.Find(properties)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(value).To(Equal(float64(9022)))
+	})
+
+	Describe("ToProperties", func() {
+		It("converts typed maps", func() {
+			p, err := bosh.ToProperties(map[string]string{"a": "b"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p).To(Equal(bosh.Properties{"a": "b"}))
+		})
+
+		It("rejects values that are not maps", func() {
+			_, err := bosh.ToProperties([]string{"a"})
+			Expect(err).To(MatchError(`value at "" is []string, not a map`))
+		})
+	})
+
+	Describe("Leaves", func() {
+		It("lists the lens of every value that is not a map", func() {
+			p := bosh.Properties{
+				"router": map[string]interface{}{
+					"port":   80,
+					"routes": []interface{}{map[string]interface{}{"name": "api"}},
+					"tls":    bosh.Properties{},
+				},
+				"ssl.enabled": true,
+			}
+
+			Expect(p.Leaves()).To(Equal([]string{
+				`"ssl.enabled"`,
+				"router.port",
+				"router.routes",
+				"router.tls",
+			}))
+		})
+	})
+})
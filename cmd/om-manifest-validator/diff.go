@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/diff"
+)
+
+type diffOptions struct {
+	environmentOptions
+	product     string
+	format      string
+	showSecrets bool
+}
+
+// diffManifests compares the deployed manifest of a product with the staged
+// one, showing what the next Apply Changes would change. Like diff(1), it
+// exits with exitFindings when there are differences.
+func diffManifests(args []string, stdout, stderr io.Writer) int {
+	opts := diffOptions{}
+
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	opts.register(flags)
+	flags.StringVar(&opts.product, "product", "", "type of the product to compare, e.g. cf")
+	flags.StringVar(&opts.format, "format", "text", "output format: text, yaml for a unified diff of the manifests, or json")
+	flags.BoolVar(&opts.showSecrets, "show-secrets", false, "show values that look like credentials instead of redacting them")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	changed, err := compareManifests(opts, stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return exitError
+	}
+
+	if changed {
+		return exitFindings
+	}
+	return exitOK
+}
+
+// compareManifests writes the differences in the requested format and reports
+// whether there are any.
+func compareManifests(opts diffOptions, stdout io.Writer) (bool, error) {
+	switch opts.format {
+	case "text", "yaml", "json":
+	default:
+		return false, fmt.Errorf("unknown format %q, expected text, yaml or json", opts.format)
+	}
+
+	if opts.target == "" || opts.product == "" {
+		return false, errors.New("--target and --product must be provided")
+	}
+
+	env := opts.environment()
+
+	deployed, err := env.GetDeployedProductManifest(opts.product)
+	if err != nil {
+		return false, err
+	}
+
+	staged, err := env.GetStagedProductManifest(opts.product)
+	if err != nil {
+		return false, err
+	}
+
+	d := diff.Compare(deployed, staged)
+	changed := !d.Empty()
+
+	var writeOpts []diff.WriteOption
+	if opts.showSecrets {
+		writeOpts = append(writeOpts, diff.WithSecrets())
+	}
+
+	switch opts.format {
+	case "yaml":
+		// The unified diff also shows changes Compare does not report, such
+		// as a new release URL.
+		unified := &bytes.Buffer{}
+		err = diff.WriteUnified(unified, deployed, staged, "deployed/"+opts.product, "staged/"+opts.product, writeOpts...)
+		if err == nil {
+			changed = changed || unified.Len() > 0
+			_, err = unified.WriteTo(stdout)
+		}
+	case "json":
+		err = d.WriteJSON(stdout, writeOpts...)
+	default:
+		err = d.WriteText(stdout, writeOpts...)
+	}
+	return changed, err
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("diff", func() {
+	var (
+		stdout, stderr *bytes.Buffer
+		server         *httptest.Server
+	)
+
+	BeforeEach(func() {
+		stdout = &bytes.Buffer{}
+		stderr = &bytes.Buffer{}
+
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/uaa/oauth/token":
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"access_token": "some-token", "token_type": "Bearer", "expires_in": 3600}`))
+			case "/api/v0/staged/products", "/api/v0/deployed/products":
+				w.Write([]byte(`[{"type": "cf", "guid": "cf-guid"}, {"type": "p-mysql", "guid": "mysql-guid"}, {"type": "p-redis", "guid": "redis-guid"}, {"type": "p-rabbitmq", "guid": "rabbitmq-guid"}]`))
+			case "/api/v0/staged/products/cf-guid/manifest":
+				w.Write([]byte(`{"manifest": {"name": "cf", "instance_groups": [{"name": "router", "instances": 3}]}}`))
+			case "/api/v0/deployed/products/cf-guid/manifest":
+				w.Write([]byte(`{"manifest": {"name": "cf", "instance_groups": [{"name": "router", "instances": 2}]}}`))
+			case "/api/v0/staged/products/redis-guid/manifest":
+				w.Write([]byte(`{"manifest": {"name": "redis", "properties": {"redis_password": "new"}}}`))
+			case "/api/v0/deployed/products/redis-guid/manifest":
+				w.Write([]byte(`{"manifest": {"name": "redis", "properties": {"redis_password": "old"}}}`))
+			case "/api/v0/staged/products/rabbitmq-guid/manifest":
+				w.Write([]byte(`{"manifest": {"name": "rabbitmq", "update": {"canaries": 2}}}`))
+			case "/api/v0/deployed/products/rabbitmq-guid/manifest":
+				w.Write([]byte(`{"manifest": {"name": "rabbitmq", "update": {"canaries": 1}}}`))
+			case "/api/v0/staged/products/mysql-guid/manifest", "/api/v0/deployed/products/mysql-guid/manifest":
+				w.Write([]byte(`{"manifest": {"name": "p-mysql"}}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	diff := func(args ...string) int {
+		args = append([]string{"diff", "--target", server.URL, "--username", "admin", "--password", "secret", "--skip-ssl-validation"}, args...)
+		return run(args, nil, stdout, stderr)
+	}
+
+	It("shows how the staged manifest differs from the deployed one", func() {
+		code := diff("--product", "cf")
+
+		Expect(stderr.String()).To(BeEmpty())
+		Expect(code).To(Equal(exitFindings))
+		Expect(stdout.String()).To(Equal("~ /instance_groups/name=router/instances: 2 -> 3\n1 change(s)\n"))
+	})
+
+	It("writes a unified diff of the manifests", func() {
+		code := diff("--product", "cf", "--format", "yaml")
+
+		Expect(code).To(Equal(exitFindings))
+		Expect(stdout.String()).To(HavePrefix("--- deployed/cf\n+++ staged/cf\n"))
+		Expect(stdout.String()).To(ContainSubstring("-  instances: 2\n+  instances: 3\n"))
+	})
+
+	It("reports changes to the update block in every format", func() {
+		code := diff("--product", "p-rabbitmq", "--format", "yaml")
+
+		Expect(code).To(Equal(exitFindings))
+		Expect(stdout.String()).To(ContainSubstring("-  canaries: 1\n+  canaries: 2\n"))
+
+		stdout.Reset()
+		code = diff("--product", "p-rabbitmq")
+
+		Expect(code).To(Equal(exitFindings))
+		Expect(stdout.String()).To(Equal("~ /update/canaries: 1 -> 2\n1 change(s)\n"))
+
+		stdout.Reset()
+		code = diff("--product", "p-rabbitmq", "--format", "json")
+
+		Expect(code).To(Equal(exitFindings))
+		Expect(stdout.String()).To(MatchJSON(`{"changes": [{"type": "changed", "kind": "update", "path": "/update/canaries", "from": 1, "to": 2}]}`))
+	})
+
+	It("shows credentials only when asked to", func() {
+		code := diff("--product", "p-redis")
+
+		Expect(code).To(Equal(exitFindings))
+		Expect(stdout.String()).To(Equal("~ /properties/redis_password: \"[REDACTED]\" -> \"[REDACTED]\"\n1 change(s)\n"))
+
+		stdout.Reset()
+		code = diff("--product", "p-redis", "--show-secrets")
+
+		Expect(code).To(Equal(exitFindings))
+		Expect(stdout.String()).To(Equal("~ /properties/redis_password: \"old\" -> \"new\"\n1 change(s)\n"))
+
+		stdout.Reset()
+		code = diff("--product", "p-redis", "--format", "yaml")
+
+		Expect(code).To(Equal(exitFindings))
+		Expect(stdout.String()).To(ContainSubstring("-  redis_password: '[REDACTED]'\n+  redis_password: '[REDACTED, CHANGED]'\n"))
+		Expect(stdout.String()).NotTo(ContainSubstring("old"))
+	})
+
+	It("writes the changes as JSON", func() {
+		code := diff("--product", "cf", "--format", "json")
+
+		Expect(code).To(Equal(exitFindings))
+		Expect(stdout.String()).To(MatchJSON(`{"changes": [{"type": "changed", "kind": "instances", "path": "/instance_groups/name=router/instances", "from": 2, "to": 3}]}`))
+	})
+
+	It("exits successfully when nothing changed", func() {
+		code := diff("--product", "p-mysql")
+
+		Expect(code).To(Equal(exitOK))
+		Expect(stdout.String()).To(Equal("0 change(s)\n"))
+	})
+
+	It("fails on unknown formats", func() {
+		code := diff("--product", "cf", "--format", "xml")
+
+		Expect(code).To(Equal(exitError))
+		Expect(stderr.String()).To(ContainSubstring(`unknown format "xml"`))
+	})
+
+	It("fails when no product is given", func() {
+		code := diff()
+
+		Expect(code).To(Equal(exitError))
+		Expect(stderr.String()).To(ContainSubstring("--target and --product must be provided"))
+	})
+})
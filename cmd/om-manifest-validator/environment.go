@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/fetcher"
+)
+
+// environmentOptions are the flags of every command that talks to Ops
+// Manager.
+type environmentOptions struct {
+	target       string
+	username     string
+	password     string
+	clientID     string
+	clientSecret string
+	caCert       string
+	skipSSL      bool
+	timeout      time.Duration
+	strict       bool
+}
+
+func (o *environmentOptions) register(flags *flag.FlagSet) {
+	flags.StringVar(&o.target, "target", os.Getenv("OM_TARGET"), "Ops Manager URL (env: OM_TARGET)")
+	flags.StringVar(&o.username, "username", os.Getenv("OM_USERNAME"), "Ops Manager username (env: OM_USERNAME)")
+	flags.StringVar(&o.password, "password", os.Getenv("OM_PASSWORD"), "Ops Manager password (env: OM_PASSWORD)")
+	flags.StringVar(&o.clientID, "client-id", os.Getenv("OM_CLIENT_ID"), "UAA client ID, used for the client_credentials grant when no username is given (env: OM_CLIENT_ID)")
+	flags.StringVar(&o.clientSecret, "client-secret", os.Getenv("OM_CLIENT_SECRET"), "UAA client secret (env: OM_CLIENT_SECRET)")
+	flags.StringVar(&o.caCert, "ca-cert", os.Getenv("OM_CA_CERT"), "PEM encoded CA certificate, or a path to one, to verify Ops Manager with (env: OM_CA_CERT)")
+	flags.BoolVar(&o.skipSSL, "skip-ssl-validation", os.Getenv("OM_SKIP_SSL_VALIDATION") == "true", "do not verify the Ops Manager certificate (env: OM_SKIP_SSL_VALIDATION)")
+	flags.DurationVar(&o.timeout, "timeout", 5*time.Minute, "give up fetching a manifest after this long, 0 to wait forever")
+	flags.BoolVar(&o.strict, "strict", false, "reject manifests that repeat a key")
+}
+
+func (o *environmentOptions) environment() *fetcher.Environment {
+	return &fetcher.Environment{
+		URL:          o.target,
+		Username:     o.username,
+		Password:     o.password,
+		ClientID:     o.clientID,
+		ClientSecret: o.clientSecret,
+		TLS: fetcher.TLSOptions{
+			CACert:            o.caCert,
+			SkipSSLValidation: o.skipSSL,
+		},
+		Timeout: o.timeout,
+		Strict:  o.strict,
+	}
+}
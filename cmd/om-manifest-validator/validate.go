@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+	"github.com/pivotal-cf-experimental/om-manifest-validator/opsfile"
+	"github.com/pivotal-cf-experimental/om-manifest-validator/release"
+	"github.com/pivotal-cf-experimental/om-manifest-validator/validator"
+)
+
+type validateOptions struct {
+	environmentOptions
+	deployed    bool
+	product     string
+	allProducts bool
+	manifest    string
+	rules       string
+	opsFiles    stringSlice
+	releases    stringSlice
+}
+
+type stringSlice []string
+
+func (s *stringSlice) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSlice) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func validate(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	opts := validateOptions{}
+
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	opts.register(flags)
+	flags.BoolVar(&opts.deployed, "deployed", false, "validate the deployed manifest of the product instead of the staged one")
+	flags.StringVar(&opts.product, "product", "", "type of the staged product to validate, e.g. cf")
+	flags.BoolVar(&opts.allProducts, "all-products", false, "validate every staged product")
+	flags.StringVar(&opts.manifest, "manifest", "", "validate a local manifest file instead of a staged product, - for stdin")
+	flags.StringVar(&opts.rules, "rules", "", "YAML or JSON file of property assertions to check")
+	flags.Var(&opts.opsFiles, "ops-file", "ops file to apply to the manifest before validating it, may be repeated")
+	flags.Var(&opts.releases, "release", "release tarball or directory to check job properties against, may be repeated")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	rules := validator.Rules()
+	if opts.rules != "" {
+		fileRules, err := validator.LoadRules(opts.rules)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %s\n", err)
+			return exitError
+		}
+		rules = append(rules, fileRules...)
+	}
+
+	if len(opts.releases) > 0 {
+		index, err := release.LoadIndex(opts.releases...)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %s\n", err)
+			return exitError
+		}
+		rules = append(rules, validator.NewUndeclaredPropertiesRule(index))
+	}
+
+	// Passing with nothing checked would look like a clean manifest to CI.
+	if len(rules) == 0 {
+		fmt.Fprintln(stderr, "error: no rules selected, pass --rules or --release")
+		return exitError
+	}
+
+	if opts.allProducts {
+		return validateAll(opts, rules, stdout, stderr)
+	}
+
+	manifest, err := loadManifest(opts, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return exitError
+	}
+
+	if len(opts.opsFiles) > 0 {
+		manifest, err = applyOpsFiles(manifest, opts.opsFiles)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %s\n", err)
+			return exitError
+		}
+	}
+
+	report := validator.Run(manifest, rules...)
+	printReport(stdout, report)
+
+	if report.HasErrors() {
+		return exitFindings
+	}
+	return exitOK
+}
+
+// validateAll validates the manifest of every staged product, printing a
+// report for each. Products whose manifest cannot be fetched are reported on
+// stderr without stopping the others.
+func validateAll(opts validateOptions, rules []validator.Rule, stdout, stderr io.Writer) int {
+	switch {
+	case opts.manifest != "" || opts.product != "" || opts.deployed:
+		fmt.Fprintln(stderr, "error: --all-products cannot be combined with --manifest, --product or --deployed")
+		return exitError
+	case len(opts.opsFiles) > 0:
+		fmt.Fprintln(stderr, "error: --all-products cannot be combined with --ops-file")
+		return exitError
+	case opts.target == "":
+		fmt.Fprintln(stderr, "error: --target must be provided")
+		return exitError
+	}
+
+	results, err := opts.environment().GetStagedProductManifests()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return exitError
+	}
+
+	code, printed := exitOK, false
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(stderr, "error: %s: %s\n", r.Product.Type, r.Err)
+			code = exitError
+			continue
+		}
+
+		if printed {
+			fmt.Fprintln(stdout)
+		}
+		printed = true
+		fmt.Fprintf(stdout, "%s %s (%s)\n", r.Product.Type, r.Product.Version, r.Product.GUID)
+
+		report := validator.Run(r.Manifest, rules...)
+		printReport(stdout, report)
+
+		if report.HasErrors() && code == exitOK {
+			code = exitFindings
+		}
+	}
+
+	return code
+}
+
+func loadManifest(opts validateOptions, stdin io.Reader) (*bosh.Manifest, error) {
+	if opts.manifest != "" {
+		return readManifest(opts.manifest, stdin)
+	}
+
+	if opts.target == "" || opts.product == "" {
+		return nil, errors.New("either --manifest or --target and --product must be provided")
+	}
+
+	env := opts.environment()
+
+	if opts.deployed {
+		return env.GetDeployedProductManifest(opts.product)
+	}
+	return env.GetStagedProductManifest(opts.product)
+}
+
+func readManifest(path string, stdin io.Reader) (*bosh.Manifest, error) {
+	var (
+		b   []byte
+		err error
+	)
+
+	if path == "-" {
+		b, err = ioutil.ReadAll(stdin)
+	} else {
+		b, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := bosh.ParseManifest(b)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse manifest %s: %s", path, err)
+	}
+
+	return manifest, nil
+}
+
+func applyOpsFiles(manifest *bosh.Manifest, paths []string) (*bosh.Manifest, error) {
+	opsFiles := make([]opsfile.Ops, 0, len(paths))
+	for _, path := range paths {
+		ops, err := opsfile.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		opsFiles = append(opsFiles, ops)
+	}
+
+	return opsfile.ApplyManifest(manifest, opsFiles...)
+}
+
+func printReport(w io.Writer, report validator.Report) {
+	for _, f := range report.Findings {
+		fmt.Fprintln(w, f)
+	}
+
+	if len(report.Findings) > 0 {
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "%d error(s), %d warning(s), %d info\n",
+		report.Count(validator.Error),
+		report.Count(validator.Warning),
+		report.Count(validator.Info),
+	)
+}
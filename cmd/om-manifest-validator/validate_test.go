@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("validate", func() {
+	var (
+		stdout, stderr *bytes.Buffer
+		rulesDir       string
+		absentRule     string
+	)
+
+	BeforeEach(func() {
+		stdout = &bytes.Buffer{}
+		stderr = &bytes.Buffer{}
+
+		var err error
+		rulesDir, err = ioutil.TempDir("", "rules")
+		Expect(err).NotTo(HaveOccurred())
+
+		// A rule that holds for every manifest without a router.
+		absentRule = filepath.Join(rulesDir, "absent.yml")
+		Expect(ioutil.WriteFile(absentRule, []byte("rules:\n- {id: router-port, instance_group: router, property: router.port, operator: absent}\n"), 0644)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(rulesDir)
+	})
+
+	It("validates a manifest read from stdin", func() {
+		stdin := strings.NewReader("name: cf\ninstance_groups:\n- name: router\n  instances: 1\n")
+
+		code := run([]string{"validate", "--manifest", "-", "--rules", absentRule}, stdin, stdout, stderr)
+
+		Expect(stderr.String()).To(BeEmpty())
+		Expect(code).To(Equal(exitOK))
+		Expect(stdout.String()).To(Equal("0 error(s), 0 warning(s), 0 info\n"))
+	})
+
+	It("fails when no rules are selected", func() {
+		stdin := strings.NewReader("name: cf\n")
+
+		code := run([]string{"validate", "--manifest", "-"}, stdin, stdout, stderr)
+
+		Expect(code).To(Equal(exitError))
+		Expect(stdout.String()).To(BeEmpty())
+		Expect(stderr.String()).To(Equal("error: no rules selected, pass --rules or --release\n"))
+	})
+
+	It("checks the assertions of a rules file", func() {
+		dir, err := ioutil.TempDir("", "rules")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		rulesPath := filepath.Join(dir, "rules.yml")
+		Expect(ioutil.WriteFile(rulesPath, []byte(`
+rules:
+- id: router-tls
+  instance_group: router
+  job: gorouter
+  property: router.enable_ssl
+  operator: equals
+  value: true
+`), 0644)).To(Succeed())
+
+		stdin := strings.NewReader("instance_groups:\n- name: router\n  jobs:\n  - name: gorouter\n    properties: {router: {enable_ssl: false}}\n")
+
+		code := run([]string{"validate", "--manifest", "-", "--rules", rulesPath}, stdin, stdout, stderr)
+
+		Expect(code).To(Equal(exitFindings))
+		Expect(stdout.String()).To(Equal("[error] router-tls: router.enable_ssl is false, expected true (router/gorouter:router.enable_ssl)\n\n1 error(s), 0 warning(s), 0 info\n"))
+	})
+
+	It("validates the manifest after applying ops files", func() {
+		dir, err := ioutil.TempDir("", "ops-files")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		rulesPath := filepath.Join(dir, "rules.yml")
+		Expect(ioutil.WriteFile(rulesPath, []byte(`
+rules:
+- id: router-tls
+  instance_group: router
+  job: gorouter
+  property: router.enable_ssl
+  operator: equals
+  value: true
+`), 0644)).To(Succeed())
+
+		opsPath := filepath.Join(dir, "enable-ssl.yml")
+		Expect(ioutil.WriteFile(opsPath, []byte(`
+- type: replace
+  path: /instance_groups/name=router/jobs/name=gorouter/properties/router/enable_ssl
+  value: true
+`), 0644)).To(Succeed())
+
+		stdin := strings.NewReader("instance_groups:\n- name: router\n  jobs:\n  - name: gorouter\n    properties: {router: {enable_ssl: false}}\n")
+
+		code := run([]string{"validate", "--manifest", "-", "--rules", rulesPath, "--ops-file", opsPath}, stdin, stdout, stderr)
+
+		Expect(stderr.String()).To(BeEmpty())
+		Expect(code).To(Equal(exitOK))
+	})
+
+	It("checks job properties against release job specs", func() {
+		dir, err := ioutil.TempDir("", "releases")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		Expect(os.MkdirAll(filepath.Join(dir, "config"), 0755)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(dir, "jobs", "gorouter"), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "config", "final.yml"), []byte("name: routing\n"), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "jobs", "gorouter", "spec"), []byte("name: gorouter\nproperties: {router.enable_ssl: {}}\n"), 0644)).To(Succeed())
+
+		stdin := strings.NewReader("instance_groups:\n- name: router\n  jobs:\n  - name: gorouter\n    release: routing\n    properties: {router: {enable_ssls: true}}\n")
+
+		code := run([]string{"validate", "--manifest", "-", "--release", dir}, stdin, stdout, stderr)
+
+		Expect(stderr.String()).To(BeEmpty())
+		Expect(code).To(Equal(exitOK))
+		Expect(stdout.String()).To(Equal("[warning] undeclared-properties: router.enable_ssls is not declared in the spec of job gorouter, did you mean router.enable_ssl? (router/gorouter:router.enable_ssls)\n\n0 error(s), 1 warning(s), 0 info\n"))
+	})
+
+	It("validates the staged or deployed manifest of a product", func() {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/uaa/oauth/token":
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"access_token": "some-token", "token_type": "Bearer", "expires_in": 3600}`))
+			case "/api/v0/staged/products":
+				w.Write([]byte(`[{"type": "cf", "guid": "cf-guid"}]`))
+			case "/api/v0/staged/products/cf-guid/manifest":
+				w.Write([]byte(`{"manifest": {"name": "cf-guid"}}`))
+			case "/api/v0/deployed/products":
+				w.Write([]byte(`[{"type": "cf", "guid": "cf-guid"}]`))
+			case "/api/v0/deployed/products/cf-guid/manifest":
+				w.Write([]byte(`{"manifest": {"name": "cf-guid", "instance_groups": [{"name": "router"}]}}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		code := run([]string{"validate", "--target", server.URL, "--username", "admin", "--password", "secret", "--product", "cf", "--skip-ssl-validation", "--rules", absentRule}, nil, stdout, stderr)
+
+		Expect(stderr.String()).To(BeEmpty())
+		Expect(code).To(Equal(exitOK))
+
+		code = run([]string{"validate", "--target", server.URL, "--username", "admin", "--password", "secret", "--product", "cf", "--skip-ssl-validation", "--deployed", "--rules", absentRule}, nil, stdout, stderr)
+
+		Expect(stderr.String()).To(BeEmpty())
+		Expect(code).To(Equal(exitOK))
+	})
+
+	It("validates every staged product", func() {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/uaa/oauth/token":
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"access_token": "some-token", "token_type": "Bearer", "expires_in": 3600}`))
+			case "/api/v0/staged/products":
+				w.Write([]byte(`[{"type": "p-bosh", "guid": "p-bosh-guid", "product_version": "2.5.0"}, {"type": "cf", "guid": "cf-guid", "product_version": "2.5.1"}, {"type": "p-mysql", "guid": "mysql-guid", "product_version": "2.6.0"}]`))
+			case "/api/v0/staged/products/cf-guid/manifest":
+				w.Write([]byte(`{"manifest": {"name": "cf"}}`))
+			case "/api/v0/staged/products/mysql-guid/manifest":
+				w.Write([]byte(`{"manifest": {"name": "p-mysql"}}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		code := run([]string{"validate", "--target", server.URL, "--username", "admin", "--password", "secret", "--all-products", "--skip-ssl-validation", "--rules", absentRule}, nil, stdout, stderr)
+
+		Expect(code).To(Equal(exitError))
+		Expect(stderr.String()).To(Equal("error: p-bosh: GET /api/v0/staged/products/p-bosh-guid/manifest: 404 Not Found\n"))
+		Expect(stdout.String()).To(Equal("cf 2.5.1 (cf-guid)\n0 error(s), 0 warning(s), 0 info\n\np-mysql 2.6.0 (mysql-guid)\n0 error(s), 0 warning(s), 0 info\n"))
+	})
+
+	It("fails when no manifest source is given", func() {
+		code := run([]string{"validate", "--rules", absentRule}, nil, stdout, stderr)
+
+		Expect(code).To(Equal(exitError))
+		Expect(stderr.String()).To(ContainSubstring("either --manifest or --target and --product must be provided"))
+	})
+
+	It("fails on unknown commands", func() {
+		code := run([]string{"frobnicate"}, nil, stdout, stderr)
+
+		Expect(code).To(Equal(exitError))
+		Expect(stderr.String()).To(ContainSubstring(`unknown command "frobnicate"`))
+	})
+})
@@ -0,0 +1,411 @@
+package diff
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+
+	"gopkg.in/yaml.v2"
+)
+
+type ChangeType string
+
+const (
+	Added   ChangeType = "added"
+	Removed ChangeType = "removed"
+	Changed ChangeType = "changed"
+)
+
+// Kind is the part of a manifest a change affects.
+type Kind string
+
+const (
+	KindFeature       Kind = "feature"
+	KindRelease       Kind = "release"
+	KindStemcell      Kind = "stemcell"
+	KindUpdate        Kind = "update"
+	KindInstanceGroup Kind = "instance_group"
+	KindInstances     Kind = "instances"
+	KindJob           Kind = "job"
+	KindAddon         Kind = "addon"
+	KindProperty      Kind = "property"
+	KindVariable      Kind = "variable"
+	KindTag           Kind = "tag"
+)
+
+// Change is a single difference between two manifests. Path is a go-patch
+// path, such as /instance_groups/name=router/instances, that resolves to the
+// changed value with Manifest.Get. From is nil for additions and To is nil for
+// removals.
+type Change struct {
+	Type ChangeType  `json:"type"`
+	Kind Kind        `json:"kind"`
+	Path string      `json:"path"`
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+type Diff struct {
+	Changes []Change `json:"changes"`
+}
+
+func (d *Diff) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+// Compare returns the semantic differences that turn from into to, usually
+// the deployed and the staged manifest of a product. Releases, stemcells,
+// instance groups, jobs, addons and variables are matched by name, and
+// properties, update blocks, features and other nested settings are compared
+// value by value.
+func Compare(from, to *bosh.Manifest) *Diff {
+	d := &Diff{}
+
+	d.compareValues(KindFeature, "/features", plain(from.Features), plain(to.Features))
+	d.compareReleases(from.Releases, to.Releases)
+	d.compareStemcells(from.Stemcells, to.Stemcells)
+	d.compareValues(KindUpdate, "/update", plain(from.Update), plain(to.Update))
+	d.compareJobs("", from.Jobs, to.Jobs)
+	d.compareProperties("/properties", from.Properties, to.Properties)
+	d.compareInstanceGroups(from.InstanceGroups, to.InstanceGroups)
+	d.compareAddons(from.Addons, to.Addons)
+	d.compareVariables(from.Variables, to.Variables)
+	d.compareValues(KindTag, "/tags", from.Tags, to.Tags)
+
+	return d
+}
+
+func (d *Diff) add(t ChangeType, kind Kind, path string, from, to interface{}) {
+	d.Changes = append(d.Changes, Change{Type: t, Kind: kind, Path: path, From: from, To: to})
+}
+
+func (d *Diff) compareReleases(from, to []bosh.Release) {
+	fromByName := map[string]bosh.Release{}
+	for _, r := range from {
+		fromByName[r.Name] = r
+	}
+	toByName := map[string]bool{}
+
+	for _, r := range to {
+		toByName[r.Name] = true
+		path := "/releases/" + selector(r.Name)
+
+		old, found := fromByName[r.Name]
+		switch {
+		case !found:
+			d.add(Added, KindRelease, path, nil, r.Version)
+		case old.Version != r.Version:
+			d.add(Changed, KindRelease, path+"/version", old.Version, r.Version)
+		case !reflect.DeepEqual(old.Stemcell, r.Stemcell):
+			d.add(Changed, KindRelease, path+"/stemcell", plain(old.Stemcell), plain(r.Stemcell))
+		}
+	}
+
+	for _, r := range from {
+		if !toByName[r.Name] {
+			d.add(Removed, KindRelease, "/releases/"+selector(r.Name), r.Version, nil)
+		}
+	}
+}
+
+func (d *Diff) compareStemcells(from, to []bosh.Stemcell) {
+	fromByAlias := map[string]bosh.Stemcell{}
+	for _, s := range from {
+		fromByAlias[s.Alias] = s
+	}
+	toByAlias := map[string]bool{}
+
+	for _, s := range to {
+		toByAlias[s.Alias] = true
+		path := "/stemcells/" + bosh.MatchingIndexToken{Key: "alias", Value: s.Alias}.String()
+
+		old, found := fromByAlias[s.Alias]
+		switch {
+		case !found:
+			d.add(Added, KindStemcell, path, nil, s.Version)
+		case old.Version != s.Version:
+			d.add(Changed, KindStemcell, path+"/version", old.Version, s.Version)
+		case old.OS != s.OS || old.Name != s.Name:
+			d.add(Changed, KindStemcell, path, stemcellName(old), stemcellName(s))
+		}
+	}
+
+	for _, s := range from {
+		if !toByAlias[s.Alias] {
+			d.add(Removed, KindStemcell, "/stemcells/"+bosh.MatchingIndexToken{Key: "alias", Value: s.Alias}.String(), s.Version, nil)
+		}
+	}
+}
+
+func stemcellName(s bosh.Stemcell) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.OS
+}
+
+func (d *Diff) compareInstanceGroups(from, to []*bosh.InstanceGroup) {
+	fromByName := map[string]*bosh.InstanceGroup{}
+	for _, ig := range from {
+		fromByName[ig.Name()] = ig
+	}
+	toByName := map[string]bool{}
+
+	for _, ig := range to {
+		toByName[ig.Name()] = true
+		path := "/instance_groups/" + selector(ig.Name())
+
+		old, found := fromByName[ig.Name()]
+		if !found {
+			d.add(Added, KindInstanceGroup, path, nil, ig.Instances())
+			continue
+		}
+
+		if old.Instances() != ig.Instances() {
+			d.add(Changed, KindInstances, path+"/instances", old.Instances(), ig.Instances())
+		}
+
+		fields := []struct {
+			name     string
+			from, to interface{}
+		}{
+			{"azs", old.AZs, ig.AZs},
+			{"lifecycle", old.Lifecycle, ig.Lifecycle},
+			{"vm_type", old.VMType, ig.VMType},
+			{"vm_resources", plain(old.VMResources), plain(ig.VMResources)},
+			{"vm_extensions", old.VMExtensions, ig.VMExtensions},
+			{"stemcell", old.Stemcell, ig.Stemcell},
+			{"persistent_disk", old.PersistentDisk, ig.PersistentDisk},
+			{"persistent_disk_type", old.PersistentDiskType, ig.PersistentDiskType},
+			{"networks", plain(old.Networks), plain(ig.Networks)},
+			{"migrated_from", plain(old.MigratedFrom), plain(ig.MigratedFrom)},
+		}
+		for _, f := range fields {
+			if !reflect.DeepEqual(f.from, f.to) {
+				d.add(Changed, KindInstanceGroup, path+"/"+f.name, f.from, f.to)
+			}
+		}
+
+		d.compareValues(KindUpdate, path+"/update", plain(old.Update), plain(ig.Update))
+		d.compareValues(KindInstanceGroup, path+"/env", plain(old.Env), plain(ig.Env))
+		d.compareProperties(path+"/properties", old.Properties(), ig.Properties())
+		d.compareJobs(path, old.Jobs(), ig.Jobs())
+	}
+
+	for _, ig := range from {
+		if !toByName[ig.Name()] {
+			d.add(Removed, KindInstanceGroup, "/instance_groups/"+selector(ig.Name()), ig.Instances(), nil)
+		}
+	}
+}
+
+func (d *Diff) compareAddons(from, to []*bosh.Addon) {
+	fromByName := map[string]*bosh.Addon{}
+	for _, a := range from {
+		fromByName[a.Name] = a
+	}
+	toByName := map[string]bool{}
+
+	for _, a := range to {
+		toByName[a.Name] = true
+		path := "/addons/" + selector(a.Name)
+
+		old, found := fromByName[a.Name]
+		if !found {
+			d.add(Added, KindAddon, path, nil, jobNames(a.Jobs))
+			continue
+		}
+
+		d.compareJobs(path, old.Jobs, a.Jobs)
+		d.compareValues(KindAddon, path+"/include", plain(old.Include), plain(a.Include))
+		d.compareValues(KindAddon, path+"/exclude", plain(old.Exclude), plain(a.Exclude))
+	}
+
+	for _, a := range from {
+		if !toByName[a.Name] {
+			d.add(Removed, KindAddon, "/addons/"+selector(a.Name), jobNames(a.Jobs), nil)
+		}
+	}
+}
+
+func jobNames(jobs []*bosh.Job) []string {
+	names := make([]string, len(jobs))
+	for i, j := range jobs {
+		names[i] = j.Name()
+	}
+	return names
+}
+
+// compareJobs compares the jobs under parentPath, which is empty for the
+// deployment jobs of a v1 manifest.
+func (d *Diff) compareJobs(parentPath string, from, to []*bosh.Job) {
+	fromByName := map[string]*bosh.Job{}
+	for _, j := range from {
+		fromByName[j.Name()] = j
+	}
+	toByName := map[string]bool{}
+
+	for _, j := range to {
+		toByName[j.Name()] = true
+		path := parentPath + "/jobs/" + selector(j.Name())
+
+		old, found := fromByName[j.Name()]
+		if !found {
+			d.add(Added, KindJob, path, nil, j.Release)
+			continue
+		}
+
+		if old.Release != j.Release {
+			d.add(Changed, KindJob, path+"/release", old.Release, j.Release)
+		}
+		d.compareValues(KindJob, path+"/consumes", old.C, j.C)
+		d.compareValues(KindJob, path+"/provides", old.Provides, j.Provides)
+		d.compareValues(KindJob, path+"/custom_provider_definitions", plain(old.CustomProviderDefinitions), plain(j.CustomProviderDefinitions))
+		d.compareProperties(path+"/properties", old.Properties(), j.Properties())
+	}
+
+	for _, j := range from {
+		if !toByName[j.Name()] {
+			d.add(Removed, KindJob, parentPath+"/jobs/"+selector(j.Name()), j.Release, nil)
+		}
+	}
+}
+
+func (d *Diff) compareVariables(from, to []bosh.Variable) {
+	fromByName := map[string]bosh.Variable{}
+	for _, v := range from {
+		fromByName[v.Name] = v
+	}
+	toByName := map[string]bool{}
+
+	for _, v := range to {
+		toByName[v.Name] = true
+		path := "/variables/" + selector(v.Name)
+
+		old, found := fromByName[v.Name]
+		switch {
+		case !found:
+			d.add(Added, KindVariable, path, nil, v.Type)
+		case old.Type != v.Type:
+			d.add(Changed, KindVariable, path+"/type", old.Type, v.Type)
+		case !reflect.DeepEqual(normalize(old.Options), normalize(v.Options)):
+			d.add(Changed, KindVariable, path+"/options", normalize(old.Options), normalize(v.Options))
+		case !reflect.DeepEqual(normalize(old.Consumes), normalize(v.Consumes)):
+			d.add(Changed, KindVariable, path+"/consumes", normalize(old.Consumes), normalize(v.Consumes))
+		case old.UpdateMode != v.UpdateMode:
+			d.add(Changed, KindVariable, path+"/update_mode", old.UpdateMode, v.UpdateMode)
+		}
+	}
+
+	for _, v := range from {
+		if !toByName[v.Name] {
+			d.add(Removed, KindVariable, "/variables/"+selector(v.Name), v.Type, nil)
+		}
+	}
+}
+
+func (d *Diff) compareProperties(path string, from, to bosh.Properties) {
+	d.compareValues(KindProperty, path, from, to)
+}
+
+// compareValues compares every value that is not a map, so that a change
+// deep inside a property or setting is reported at its full path.
+func (d *Diff) compareValues(kind Kind, path string, from, to interface{}) {
+	fromLeaves, toLeaves := leaves(from, path), leaves(to, path)
+
+	paths := make([]string, 0, len(fromLeaves)+len(toLeaves))
+	for p := range fromLeaves {
+		paths = append(paths, p)
+	}
+	for p := range toLeaves {
+		if _, found := fromLeaves[p]; !found {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		old, inFrom := fromLeaves[p]
+		value, inTo := toLeaves[p]
+
+		switch {
+		case !inFrom:
+			d.add(Added, kind, p, nil, value)
+		case !inTo:
+			d.add(Removed, kind, p, old, nil)
+		case !reflect.DeepEqual(old, value):
+			d.add(Changed, kind, p, old, value)
+		}
+	}
+}
+
+// leaves maps the path of every value in v that is not a map, or is an empty
+// map, to its normalized value.
+func leaves(v interface{}, path string) map[string]interface{} {
+	found := map[string]interface{}{}
+
+	var walk func(v interface{}, path string, root bool)
+	walk = func(v interface{}, path string, root bool) {
+		props, err := bosh.ToProperties(v)
+		if err != nil || (len(props) == 0 && !root) {
+			found[path] = normalize(v)
+			return
+		}
+		for k, e := range props {
+			walk(e, path+"/"+escape(fmt.Sprint(k)), false)
+		}
+	}
+
+	if v != nil {
+		walk(v, path, true)
+	}
+	return found
+}
+
+// normalize converts maps of any kind into map[string]interface{}, so values
+// compare equal however they were decoded and can be encoded as JSON.
+func normalize(v interface{}) interface{} {
+	if props, err := bosh.ToProperties(v); err == nil {
+		out := make(map[string]interface{}, len(props))
+		for k, e := range props {
+			out[fmt.Sprint(k)] = normalize(e)
+		}
+		return out
+	}
+
+	if list, ok := v.([]interface{}); ok {
+		out := make([]interface{}, len(list))
+		for i, e := range list {
+			out[i] = normalize(e)
+		}
+		return out
+	}
+
+	return v
+}
+
+// plain returns the maps and lists a struct encodes to, so its fields are
+// compared and reported by their manifest keys. Nil pointers become nil.
+func plain(v interface{}) interface{} {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return v
+	}
+
+	var out interface{}
+	if err := yaml.Unmarshal(b, &out); err != nil {
+		return v
+	}
+	return normalize(out)
+}
+
+// selector returns the go-patch token of the list element with the given name.
+func selector(name string) string {
+	return bosh.MatchingIndexToken{Key: "name", Value: name}.String()
+}
+
+func escape(key string) string {
+	return bosh.KeyToken{Key: key}.String()
+}
@@ -0,0 +1,346 @@
+package diff_test
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+	"github.com/pivotal-cf-experimental/om-manifest-validator/diff"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const deployedManifest = `---
+name: cf
+releases:
+- name: routing
+  version: 0.180.0
+- name: capi
+  version: 1.71.0
+stemcells:
+- alias: default
+  os: ubuntu-xenial
+  version: "170.15"
+instance_groups:
+- name: router
+  instances: 2
+  vm_type: small
+  jobs:
+  - name: gorouter
+    release: routing
+    properties:
+      router:
+        port: 80
+        enable_ssl: false
+        tls_pem: [{cert_chain: a}]
+- name: api
+  instances: 1
+  jobs:
+  - name: cloud_controller_ng
+    release: capi
+variables:
+- name: router_ca
+  type: certificate
+  options: {is_ca: true, common_name: routerCA}
+- name: uaa_admin
+  type: password
+`
+
+const stagedManifest = `---
+name: cf
+releases:
+- name: routing
+  version: 0.184.0
+- name: capi
+  version: 1.71.0
+- name: bpm
+  version: 1.0.0
+stemcells:
+- alias: default
+  os: ubuntu-xenial
+  version: "170.15"
+instance_groups:
+- name: router
+  instances: 3
+  vm_type: small
+  jobs:
+  - name: gorouter
+    release: routing
+    properties:
+      router:
+        port: 80
+        enable_ssl: true
+        tls_pem: [{cert_chain: b}]
+        route_services_secret: secret
+  - name: bpm
+    release: bpm
+- name: doppler
+  instances: 1
+  jobs: []
+variables:
+- name: router_ca
+  type: certificate
+  options: {is_ca: true, common_name: newRouterCA}
+`
+
+func parse(s string) *bosh.Manifest {
+	m, err := bosh.ParseManifest([]byte(s))
+	Expect(err).NotTo(HaveOccurred())
+	return m
+}
+
+var _ = Describe("Compare", func() {
+	var from, to *bosh.Manifest
+
+	BeforeEach(func() {
+		from = parse(deployedManifest)
+		to = parse(stagedManifest)
+	})
+
+	It("reports nothing for equal manifests", func() {
+		Expect(diff.Compare(from, parse(deployedManifest)).Empty()).To(BeTrue())
+	})
+
+	It("reports every change in manifest order", func() {
+		d := diff.Compare(from, to)
+
+		Expect(d.Changes).To(Equal([]diff.Change{
+			{Type: diff.Changed, Kind: diff.KindRelease, Path: "/releases/name=routing/version", From: "0.180.0", To: "0.184.0"},
+			{Type: diff.Added, Kind: diff.KindRelease, Path: "/releases/name=bpm", To: "1.0.0"},
+			{Type: diff.Changed, Kind: diff.KindInstances, Path: "/instance_groups/name=router/instances", From: 2, To: 3},
+			{Type: diff.Changed, Kind: diff.KindProperty, Path: "/instance_groups/name=router/jobs/name=gorouter/properties/router/enable_ssl", From: false, To: true},
+			{Type: diff.Added, Kind: diff.KindProperty, Path: "/instance_groups/name=router/jobs/name=gorouter/properties/router/route_services_secret", To: "secret"},
+			{Type: diff.Changed, Kind: diff.KindProperty, Path: "/instance_groups/name=router/jobs/name=gorouter/properties/router/tls_pem",
+				From: []interface{}{map[string]interface{}{"cert_chain": "a"}},
+				To:   []interface{}{map[string]interface{}{"cert_chain": "b"}},
+			},
+			{Type: diff.Added, Kind: diff.KindJob, Path: "/instance_groups/name=router/jobs/name=bpm", To: "bpm"},
+			{Type: diff.Added, Kind: diff.KindInstanceGroup, Path: "/instance_groups/name=doppler", To: 1},
+			{Type: diff.Removed, Kind: diff.KindInstanceGroup, Path: "/instance_groups/name=api", From: 1},
+			{Type: diff.Changed, Kind: diff.KindVariable, Path: "/variables/name=router_ca/options",
+				From: map[string]interface{}{"is_ca": true, "common_name": "routerCA"},
+				To:   map[string]interface{}{"is_ca": true, "common_name": "newRouterCA"},
+			},
+			{Type: diff.Removed, Kind: diff.KindVariable, Path: "/variables/name=uaa_admin", From: "password"},
+		}))
+	})
+
+	It("reports paths that resolve in the manifests", func() {
+		for _, c := range diff.Compare(from, to).Changes {
+			m := to
+			if c.Type == diff.Removed {
+				m = from
+			}
+
+			_, err := m.Get(c.Path)
+			Expect(err).NotTo(HaveOccurred(), c.Path)
+		}
+	})
+
+	It("reports changes to the settings around releases, jobs and properties", func() {
+		from = parse(`---
+features: {use_dns_addresses: false}
+update: {canaries: 1, max_in_flight: 1}
+jobs:
+- name: nats
+  release: nats
+instance_groups:
+- name: router
+  instances: 1
+  networks: [{name: default}]
+  env: {bosh: {password: old}}
+  jobs:
+  - name: gorouter
+    consumes: {nats: {from: nats}}
+addons:
+- name: dns
+  jobs: [{name: bosh-dns, release: bosh-dns}]
+tags: {team: routing}
+`)
+		to = parse(`---
+features: {use_dns_addresses: true}
+update: {canaries: 2, max_in_flight: 1}
+jobs:
+- name: nats
+  release: nats-v2
+instance_groups:
+- name: router
+  instances: 1
+  lifecycle: errand
+  networks: [{name: private}]
+  env: {bosh: {password: new}}
+  update: {serial: true}
+  jobs:
+  - name: gorouter
+    consumes: {nats: {from: nats-tls}}
+    provides: {gorouter: {as: router}}
+addons:
+- name: dns
+  jobs: [{name: bosh-dns, release: bosh-dns}]
+  include: {stemcell: [{os: ubuntu-xenial}]}
+- name: syslog
+  jobs: [{name: syslog_forwarder, release: syslog}]
+tags: {team: networking}
+`)
+
+		Expect(diff.Compare(from, to).Changes).To(Equal([]diff.Change{
+			{Type: diff.Changed, Kind: diff.KindFeature, Path: "/features/use_dns_addresses", From: false, To: true},
+			{Type: diff.Changed, Kind: diff.KindUpdate, Path: "/update/canaries", From: 1, To: 2},
+			{Type: diff.Changed, Kind: diff.KindJob, Path: "/jobs/name=nats/release", From: "nats", To: "nats-v2"},
+			{Type: diff.Changed, Kind: diff.KindInstanceGroup, Path: "/instance_groups/name=router/lifecycle", From: "", To: "errand"},
+			{Type: diff.Changed, Kind: diff.KindInstanceGroup, Path: "/instance_groups/name=router/networks",
+				From: []interface{}{map[string]interface{}{"name": "default"}},
+				To:   []interface{}{map[string]interface{}{"name": "private"}},
+			},
+			{Type: diff.Added, Kind: diff.KindUpdate, Path: "/instance_groups/name=router/update/serial", To: true},
+			{Type: diff.Changed, Kind: diff.KindInstanceGroup, Path: "/instance_groups/name=router/env/bosh/password", From: "old", To: "new"},
+			{Type: diff.Changed, Kind: diff.KindJob, Path: "/instance_groups/name=router/jobs/name=gorouter/consumes/nats/from", From: "nats", To: "nats-tls"},
+			{Type: diff.Added, Kind: diff.KindJob, Path: "/instance_groups/name=router/jobs/name=gorouter/provides/gorouter/as", To: "router"},
+			{Type: diff.Added, Kind: diff.KindAddon, Path: "/addons/name=dns/include/stemcell", To: []interface{}{map[string]interface{}{"os": "ubuntu-xenial"}}},
+			{Type: diff.Added, Kind: diff.KindAddon, Path: "/addons/name=syslog", To: []string{"syslog_forwarder"}},
+			{Type: diff.Changed, Kind: diff.KindTag, Path: "/tags/team", From: "routing", To: "networking"},
+		}))
+	})
+
+	It("escapes property keys in paths", func() {
+		from = parse("instance_groups:\n- name: router\n  properties: {a/b: 1}\n")
+		to = parse("instance_groups:\n- name: router\n  properties: {a/b: 2}\n")
+
+		Expect(diff.Compare(from, to).Changes).To(ConsistOf(
+			diff.Change{Type: diff.Changed, Kind: diff.KindProperty, Path: "/instance_groups/name=router/properties/a~1b", From: 1, To: 2},
+		))
+	})
+
+	Describe("WriteText", func() {
+		It("writes one line per change", func() {
+			d := diff.Compare(parse(deployedManifest), parse(stagedManifest))
+			d.Changes = d.Changes[:4]
+
+			out := &bytes.Buffer{}
+			Expect(d.WriteText(out)).To(Succeed())
+
+			Expect(out.String()).To(Equal(`~ /releases/name=routing/version: "0.180.0" -> "0.184.0"
++ /releases/name=bpm: "1.0.0"
+~ /instance_groups/name=router/instances: 2 -> 3
+~ /instance_groups/name=router/jobs/name=gorouter/properties/router/enable_ssl: false -> true
+4 change(s)
+`))
+		})
+
+		It("redacts values that look like credentials unless asked not to", func() {
+			d := &diff.Diff{Changes: []diff.Change{
+				{Type: diff.Changed, Kind: diff.KindProperty, Path: "/properties/uaa/admin/client_secret", From: "old", To: "new"},
+			}}
+
+			out := &bytes.Buffer{}
+			Expect(d.WriteText(out)).To(Succeed())
+			Expect(out.String()).To(Equal("~ /properties/uaa/admin/client_secret: \"[REDACTED]\" -> \"[REDACTED]\"\n1 change(s)\n"))
+
+			out.Reset()
+			Expect(d.WriteText(out, diff.WithSecrets())).To(Succeed())
+			Expect(out.String()).To(Equal("~ /properties/uaa/admin/client_secret: \"old\" -> \"new\"\n1 change(s)\n"))
+		})
+	})
+
+	Describe("WriteJSON", func() {
+		It("writes the changes as JSON", func() {
+			out := &bytes.Buffer{}
+			Expect(diff.Compare(from, to).WriteJSON(out)).To(Succeed())
+
+			var decoded struct {
+				Changes []map[string]interface{} `json:"changes"`
+			}
+			Expect(json.Unmarshal(out.Bytes(), &decoded)).To(Succeed())
+			Expect(decoded.Changes).To(HaveLen(11))
+			Expect(decoded.Changes[0]).To(Equal(map[string]interface{}{
+				"type": "changed",
+				"kind": "release",
+				"path": "/releases/name=routing/version",
+				"from": "0.180.0",
+				"to":   "0.184.0",
+			}))
+		})
+
+		It("redacts values that look like credentials", func() {
+			d := &diff.Diff{Changes: []diff.Change{
+				{Type: diff.Added, Kind: diff.KindProperty, Path: "/properties/router/route_services_secret", To: "hunter2"},
+				{Type: diff.Changed, Kind: diff.KindProperty, Path: "/properties/router/tls_pem", From: []interface{}{map[string]interface{}{"cert_chain": "a", "name": "x"}}, To: nil},
+				{Type: diff.Changed, Kind: diff.KindInstances, Path: "/instance_groups/name=secret_store/instances", From: 1, To: 2},
+			}}
+
+			out := &bytes.Buffer{}
+			Expect(d.WriteJSON(out)).To(Succeed())
+			Expect(out.String()).To(MatchJSON(`{"changes": [
+				{"type": "added", "kind": "property", "path": "/properties/router/route_services_secret", "to": "[REDACTED]"},
+				{"type": "changed", "kind": "property", "path": "/properties/router/tls_pem", "from": [{"cert_chain": "[REDACTED]", "name": "x"}]},
+				{"type": "changed", "kind": "instances", "path": "/instance_groups/name=secret_store/instances", "from": 1, "to": 2}
+			]}`))
+			Expect(d.Changes[0].To).To(Equal("hunter2"))
+
+			out.Reset()
+			Expect(d.WriteJSON(out, diff.WithSecrets())).To(Succeed())
+			Expect(out.String()).To(ContainSubstring(`"to": "hunter2"`))
+		})
+
+		It("writes an empty list when nothing changed", func() {
+			out := &bytes.Buffer{}
+			Expect(diff.Compare(from, from).WriteJSON(out)).To(Succeed())
+			Expect(out.String()).To(MatchJSON(`{"changes": []}`))
+		})
+	})
+})
+
+var _ = Describe("WriteUnified", func() {
+	It("writes a unified diff of the manifests", func() {
+		from := parse("name: cf\nreleases:\n- name: routing\n  version: 0.180.0\n- name: capi\n  version: 1.71.0\n")
+		to := parse("name: cf\nreleases:\n- name: routing\n  version: 0.184.0\n- name: capi\n  version: 1.71.0\n")
+
+		out := &bytes.Buffer{}
+		Expect(diff.WriteUnified(out, from, to, "deployed", "staged")).To(Succeed())
+
+		Expect(out.String()).To(Equal(`--- deployed
++++ staged
+@@ -1,6 +1,6 @@
+ name: cf
+ releases:
+ - name: routing
+-  version: 0.180.0
++  version: 0.184.0
+ - name: capi
+   version: 1.71.0
+`))
+	})
+
+	It("redacts values that look like credentials unless asked not to", func() {
+		from := parse("name: cf\nproperties:\n  admin_password: old\n  port: 80\n")
+		to := parse("name: cf\nproperties:\n  admin_password: new\n  port: 443\n")
+
+		out := &bytes.Buffer{}
+		Expect(diff.WriteUnified(out, from, to, "deployed", "staged")).To(Succeed())
+		Expect(out.String()).To(ContainSubstring(" properties:\n-  admin_password: '[REDACTED]'\n-  port: 80\n+  admin_password: '[REDACTED, CHANGED]'\n+  port: 443\n"))
+
+		out.Reset()
+		Expect(diff.WriteUnified(out, from, to, "deployed", "staged", diff.WithSecrets())).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("-  admin_password: old\n-  port: 80\n+  admin_password: new\n+  port: 443\n"))
+	})
+
+	It("shows credentials that changed deep inside lists as a redacted pair", func() {
+		from := parse("instance_groups:\n- name: api\n  instances: 1\n- name: router\n  instances: 1\n  jobs:\n  - name: gorouter\n    properties: {router: {tls_key: old}}\n")
+		to := parse("instance_groups:\n- name: router\n  instances: 1\n  jobs:\n  - name: gorouter\n    properties: {router: {tls_key: new}}\n- name: api\n  instances: 1\n")
+
+		out := &bytes.Buffer{}
+		Expect(diff.WriteUnified(out, from, to, "deployed", "staged")).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("-        tls_key: '[REDACTED]'\n+        tls_key: '[REDACTED, CHANGED]'\n"))
+		Expect(out.String()).NotTo(ContainSubstring("old"))
+		Expect(out.String()).NotTo(ContainSubstring("new"))
+	})
+
+	It("writes nothing for equal manifests", func() {
+		m := parse(deployedManifest)
+
+		out := &bytes.Buffer{}
+		Expect(diff.WriteUnified(out, m, m, "deployed", "staged")).To(Succeed())
+		Expect(out.String()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,66 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// WriteText writes one line per change: "+" for additions, "-" for removals
+// and "~" for changes, followed by the path and the values involved. Values
+// that look like credentials are redacted unless WithSecrets is given.
+func (d *Diff) WriteText(w io.Writer, opts ...WriteOption) error {
+	for _, c := range d.written(opts) {
+		var err error
+		switch c.Type {
+		case Added:
+			_, err = fmt.Fprintf(w, "+ %s: %s\n", c.Path, formatValue(c.To))
+		case Removed:
+			_, err = fmt.Fprintf(w, "- %s: %s\n", c.Path, formatValue(c.From))
+		default:
+			_, err = fmt.Fprintf(w, "~ %s: %s -> %s\n", c.Path, formatValue(c.From), formatValue(c.To))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "%d change(s)\n", len(d.Changes))
+	return err
+}
+
+// WriteJSON writes the changes as an indented JSON object, redacting values
+// the way WriteText does.
+func (d *Diff) WriteJSON(w io.Writer, opts ...WriteOption) error {
+	changes := d.written(opts)
+	if changes == nil {
+		changes = []Change{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(Diff{Changes: changes})
+}
+
+// formatValue renders values as compact JSON, which keeps strings that look
+// like numbers or booleans distinguishable from them.
+func formatValue(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// written returns the changes as they are written, leaving d untouched.
+func (d *Diff) written(opts []WriteOption) []Change {
+	if newWriteOptions(opts).showSecrets || d.Changes == nil {
+		return d.Changes
+	}
+
+	changes := make([]Change, len(d.Changes))
+	for i, c := range d.Changes {
+		changes[i] = redactChange(c)
+	}
+	return changes
+}
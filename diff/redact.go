@@ -0,0 +1,153 @@
+package diff
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Redacted replaces values that look like credentials when writing diffs, and
+// RedactedChanged replaces the new value of one that changed in a unified
+// diff, which would otherwise show the same line on both sides.
+const (
+	Redacted        = "[REDACTED]"
+	RedactedChanged = "[REDACTED, CHANGED]"
+)
+
+// WriteOption configures WriteText, WriteJSON and WriteUnified.
+type WriteOption func(*writeOptions)
+
+type writeOptions struct {
+	showSecrets bool
+}
+
+// WithSecrets writes values that look like credentials instead of Redacted.
+func WithSecrets() WriteOption {
+	return func(o *writeOptions) {
+		o.showSecrets = true
+	}
+}
+
+func newWriteOptions(opts []WriteOption) writeOptions {
+	options := writeOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// isSecret reports whether a key looks like it holds a credential.
+func isSecret(key string) bool {
+	lower := strings.ToLower(key)
+	for _, word := range []string{"password", "secret", "key", "cert", "token", "credential", "private"} {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactChange hides both values of a change whose path goes through a secret
+// key, and the secret values nested inside them otherwise.
+func redactChange(c Change) Change {
+	if p, err := bosh.ParsePointer(c.Path); err == nil {
+		for _, t := range p.Tokens {
+			if k, ok := t.(bosh.KeyToken); ok && isSecret(k.Key) {
+				if c.From != nil {
+					c.From = Redacted
+				}
+				if c.To != nil {
+					c.To = Redacted
+				}
+				return c
+			}
+		}
+	}
+
+	c.From = redactValue(c.From)
+	c.To = redactValue(c.To)
+	return c
+}
+
+// redactValue returns a copy of v in which the values of secret keys, at any
+// depth, are replaced by Redacted.
+func redactValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for k, e := range value {
+			if isSecret(k) {
+				out[k] = Redacted
+				continue
+			}
+			out[k] = redactValue(e)
+		}
+		return out
+	case bosh.Properties:
+		out := make(bosh.Properties, len(value))
+		for k, e := range value {
+			if name, ok := k.(string); ok && isSecret(name) {
+				out[k] = Redacted
+				continue
+			}
+			out[k] = redactValue(e)
+		}
+		return out
+	case yaml.MapSlice:
+		out := make(yaml.MapSlice, len(value))
+		for i, item := range value {
+			if k, ok := item.Key.(string); ok && isSecret(k) {
+				out[i] = yaml.MapItem{Key: item.Key, Value: Redacted}
+				continue
+			}
+			out[i] = yaml.MapItem{Key: item.Key, Value: redactValue(item.Value)}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(value))
+		for i, e := range value {
+			out[i] = redactValue(e)
+		}
+		return out
+	}
+
+	return v
+}
+
+// markChangedSecrets walks the renderings from and to of two manifests along
+// with redacted, the redacted copy of to, and replaces the secret values of to
+// that differ from their counterparts in from by RedactedChanged.
+func markChangedSecrets(from, to, redacted interface{}) {
+	switch value := to.(type) {
+	case yaml.MapSlice:
+		out := redacted.(yaml.MapSlice)
+		for i, item := range value {
+			old, found, _ := bosh.MapValue(from, fmt.Sprint(item.Key))
+			if !found {
+				continue
+			}
+			if k, ok := item.Key.(string); ok && isSecret(k) {
+				if !reflect.DeepEqual(old, item.Value) {
+					out[i].Value = RedactedChanged
+				}
+				continue
+			}
+			markChangedSecrets(old, item.Value, out[i].Value)
+		}
+	case []interface{}:
+		original, ok := from.([]interface{})
+		if !ok {
+			return
+		}
+		out := redacted.([]interface{})
+		for i, old := range bosh.Counterparts(value, original) {
+			if old != nil {
+				markChangedSecrets(old, value[i], out[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+
+	"gopkg.in/yaml.v2"
+)
+
+const unifiedContext = 3
+
+// WriteUnified writes a unified diff of the YAML renderings of two manifests,
+// labelled fromLabel and toLabel. Nothing is written when they are equal.
+// Values that look like credentials are redacted unless WithSecrets is given;
+// those that changed are shown as RedactedChanged on the side of to.
+func WriteUnified(w io.Writer, from, to *bosh.Manifest, fromLabel, toLabel string, opts ...WriteOption) error {
+	a, err := from.MarshalYAML()
+	if err != nil {
+		return err
+	}
+	b, err := to.MarshalYAML()
+	if err != nil {
+		return err
+	}
+
+	if !newWriteOptions(opts).showSecrets {
+		redacted := redactValue(b)
+		markChangedSecrets(a, b, redacted)
+		a, b = redactValue(a), redacted
+	}
+
+	aText, err := yaml.Marshal(a)
+	if err != nil {
+		return err
+	}
+	bText, err := yaml.Marshal(b)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, unified(lines(aText), lines(bText), fromLabel, toLabel))
+	return err
+}
+
+func lines(b []byte) []string {
+	s := strings.TrimSuffix(string(b), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+type edit struct {
+	op   byte // ' ', '-' or '+'
+	line string
+}
+
+func unified(a, b []string, fromLabel, toLabel string) string {
+	edits := editScript(a, b)
+
+	var changed []int
+	for i, e := range edits {
+		if e.op != ' ' {
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return ""
+	}
+
+	out := &strings.Builder{}
+	fmt.Fprintf(out, "--- %s\n+++ %s\n", fromLabel, toLabel)
+
+	// Group changes whose context would overlap into one hunk.
+	for start := 0; start < len(changed); {
+		end := start
+		for end+1 < len(changed) && changed[end+1]-changed[end] <= 2*unifiedContext {
+			end++
+		}
+
+		first := changed[start] - unifiedContext
+		if first < 0 {
+			first = 0
+		}
+		last := changed[end] + unifiedContext
+		if last >= len(edits) {
+			last = len(edits) - 1
+		}
+
+		writeHunk(out, edits, first, last)
+		start = end + 1
+	}
+
+	return out.String()
+}
+
+func writeHunk(out *strings.Builder, edits []edit, first, last int) {
+	// Line numbers of the first line of the hunk on either side.
+	aLine, bLine := 1, 1
+	for _, e := range edits[:first] {
+		if e.op != '+' {
+			aLine++
+		}
+		if e.op != '-' {
+			bLine++
+		}
+	}
+
+	aCount, bCount := 0, 0
+	for _, e := range edits[first : last+1] {
+		if e.op != '+' {
+			aCount++
+		}
+		if e.op != '-' {
+			bCount++
+		}
+	}
+
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(aLine, aCount), hunkRange(bLine, bCount))
+	for _, e := range edits[first : last+1] {
+		fmt.Fprintf(out, "%c%s\n", e.op, e.line)
+	}
+}
+
+// hunkRange follows the unified format, where an empty range starts at the
+// line before it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// editScript returns a shortest edit script turning a into b, using Myers'
+// algorithm.
+func editScript(a, b []string) []edit {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+
+	// trace[d] holds v[-d..d] after step d, which is all backtracking needs.
+	var trace [][]int
+
+search:
+	for d := 0; d <= max; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+				break search
+			}
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+	}
+
+	var edits []edit
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		previous := trace[d-1]
+		at := func(k int) int { return previous[k+d-1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{' ', a[x]})
+		}
+
+		if x == prevX {
+			y--
+			edits = append(edits, edit{'+', b[y]})
+		} else {
+			x--
+			edits = append(edits, edit{'-', a[x]})
+		}
+	}
+
+	for x > 0 && y > 0 {
+		x--
+		y--
+		edits = append(edits, edit{' ', a[x]})
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
@@ -0,0 +1,142 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// APIError is returned when Ops Manager answers with a status other than 200
+// OK. Messages holds the errors of an Ops Manager error response, if any, and
+// Header the response headers with credentials redacted.
+type APIError struct {
+	Method     string
+	Endpoint   string
+	StatusCode int
+	Status     string
+	Messages   []string
+	RequestID  string
+	Header     http.Header
+}
+
+func (e *APIError) Error() string {
+	s := fmt.Sprintf("%s %s: %s", e.Method, e.Endpoint, e.Status)
+	if len(e.Messages) > 0 {
+		s += ": " + strings.Join(e.Messages, "; ")
+	}
+	if e.RequestID != "" {
+		s += fmt.Sprintf(" (request ID %s)", e.RequestID)
+	}
+	return s
+}
+
+func newAPIError(res *http.Response, body []byte) *APIError {
+	return &APIError{
+		Method:     res.Request.Method,
+		Endpoint:   res.Request.URL.Path,
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		Messages:   errorMessages(body),
+		RequestID:  requestID(res.Header),
+		Header:     RedactHeaders(res.Header),
+	}
+}
+
+// errorMessages reads the error formats of the Ops Manager API, e.g.
+// {"errors": {"base": ["..."]}} and {"errors": ["..."]}, and of UAA, e.g.
+// {"error": "...", "error_description": "..."}.
+func errorMessages(body []byte) []string {
+	var response struct {
+		Errors           interface{} `json:"errors"`
+		Error            string      `json:"error"`
+		ErrorDescription string      `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil
+	}
+
+	var messages []string
+	switch errs := response.Errors.(type) {
+	case []interface{}:
+		for _, e := range errs {
+			messages = append(messages, fmt.Sprint(e))
+		}
+	case map[string]interface{}:
+		fields := make([]string, 0, len(errs))
+		for field := range errs {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		for _, field := range fields {
+			list, ok := errs[field].([]interface{})
+			if !ok {
+				list = []interface{}{errs[field]}
+			}
+			for _, e := range list {
+				if field == "base" {
+					messages = append(messages, fmt.Sprint(e))
+				} else {
+					messages = append(messages, fmt.Sprintf("%s %v", field, e))
+				}
+			}
+		}
+	case string:
+		messages = append(messages, errs)
+	}
+
+	switch {
+	case response.Error != "" && response.ErrorDescription != "":
+		messages = append(messages, response.Error+": "+response.ErrorDescription)
+	case response.Error != "":
+		messages = append(messages, response.Error)
+	}
+
+	return messages
+}
+
+func requestID(h http.Header) string {
+	for _, name := range []string{"X-Request-Id", "X-Vcap-Request-Id"} {
+		if id := h.Get(name); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// RedactHeaders returns a copy of h with the values of headers that may carry
+// credentials replaced by [REDACTED].
+func RedactHeaders(h http.Header) http.Header {
+	redacted := make(http.Header, len(h))
+	for name, values := range h {
+		if isSensitiveHeader(name) {
+			redacted[name] = []string{"[REDACTED]"}
+			continue
+		}
+		redacted[name] = append([]string(nil), values...)
+	}
+	return redacted
+}
+
+func isSensitiveHeader(name string) bool {
+	if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+		return true
+	}
+
+	lower := strings.ToLower(name)
+	for _, word := range []string{"token", "secret", "password", "api-key", "session"} {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package fetcher_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/fetcher"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("API errors and retries", func() {
+	var (
+		server   *httptest.Server
+		env      *fetcher.Environment
+		attempts int32
+		handler  func(w http.ResponseWriter, attempt int32)
+	)
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&attempts, 0)
+
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/uaa/oauth/token" {
+				issueToken(w)
+				return
+			}
+			handler(w, atomic.AddInt32(&attempts, 1))
+		}))
+
+		env = &fetcher.Environment{
+			URL:      server.URL,
+			Username: "admin",
+			Password: "secret",
+			TLS:      fetcher.TLSOptions{CACert: caCert(server)},
+			Retry:    fetcher.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("returns an APIError describing the failed request", func() {
+		handler = func(w http.ResponseWriter, _ int32) {
+			w.Header().Set("X-Request-Id", "some-request-id")
+			w.Header().Set("Set-Cookie", "session=abc")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"errors": {"base": ["product is not staged"], "guid": ["is invalid"]}}`))
+		}
+
+		_, err := env.GetStagedProductManifestByGUID("cf-guid")
+
+		var apiErr *fetcher.APIError
+		Expect(errors.As(err, &apiErr)).To(BeTrue())
+		Expect(apiErr.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+		Expect(apiErr.Endpoint).To(Equal("/api/v0/staged/products/cf-guid/manifest"))
+		Expect(apiErr.Messages).To(Equal([]string{"product is not staged", "guid is invalid"}))
+		Expect(apiErr.RequestID).To(Equal("some-request-id"))
+		Expect(apiErr.Header.Get("Set-Cookie")).To(Equal("[REDACTED]"))
+		Expect(err).To(MatchError("GET /api/v0/staged/products/cf-guid/manifest: 422 Unprocessable Entity: product is not staged; guid is invalid (request ID some-request-id)"))
+		Expect(atomic.LoadInt32(&attempts)).To(Equal(int32(1)))
+	})
+
+	It("retries server errors and rate limiting", func() {
+		handler = func(w http.ResponseWriter, attempt int32) {
+			switch attempt {
+			case 1:
+				w.WriteHeader(http.StatusBadGateway)
+			case 2:
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+			default:
+				w.Write([]byte(`{"manifest": {"name": "cf"}}`))
+			}
+		}
+
+		manifest, err := env.GetStagedProductManifestByGUID("cf-guid")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifest.Name).To(Equal("cf"))
+		Expect(atomic.LoadInt32(&attempts)).To(Equal(int32(3)))
+	})
+
+	It("waits no longer than MaxDelay whatever Retry-After asks for", func() {
+		handler = func(w http.ResponseWriter, attempt int32) {
+			if attempt == 1 {
+				w.Header().Set("Retry-After", "3600")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.Write([]byte(`{"manifest": {"name": "cf"}}`))
+		}
+
+		start := time.Now()
+		_, err := env.GetStagedProductManifestByGUID("cf-guid")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		Expect(atomic.LoadInt32(&attempts)).To(Equal(int32(2)))
+	})
+
+	It("gives up when the delay would outlast the timeout", func() {
+		handler = func(w http.ResponseWriter, _ int32) {
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		env.Retry.MaxDelay = time.Minute
+		env.Timeout = 5 * time.Second
+
+		start := time.Now()
+		_, err := env.GetStagedProductManifestByGUID("cf-guid")
+		Expect(err).To(MatchError(ContainSubstring("503 Service Unavailable")))
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		Expect(atomic.LoadInt32(&attempts)).To(Equal(int32(1)))
+	})
+
+	It("retries dropped connections", func() {
+		handler = func(w http.ResponseWriter, attempt int32) {
+			if attempt == 1 {
+				conn, _, err := w.(http.Hijacker).Hijack()
+				Expect(err).NotTo(HaveOccurred())
+				conn.Close()
+				return
+			}
+			w.Write([]byte(`{"manifest": {"name": "cf"}}`))
+		}
+
+		_, err := env.GetStagedProductManifestByGUID("cf-guid")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(atomic.LoadInt32(&attempts)).To(Equal(int32(2)))
+	})
+
+	It("gives up after the last attempt", func() {
+		handler = func(w http.ResponseWriter, _ int32) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		_, err := env.GetStagedProductManifestByGUID("cf-guid")
+		Expect(err).To(MatchError(ContainSubstring("503 Service Unavailable")))
+		Expect(atomic.LoadInt32(&attempts)).To(Equal(int32(3)))
+	})
+
+	It("does not retry client errors", func() {
+		handler = func(w http.ResponseWriter, _ int32) {
+			w.WriteHeader(http.StatusNotFound)
+		}
+
+		_, err := env.GetProductGUID("cf")
+		Expect(err).To(MatchError("GET /api/v0/staged/products: 404 Not Found"))
+		Expect(atomic.LoadInt32(&attempts)).To(Equal(int32(1)))
+	})
+})
+
+var _ = Describe("RedactHeaders", func() {
+	It("hides credentials and keeps everything else", func() {
+		h := http.Header{
+			"Authorization":  {"Bearer some-token"},
+			"X-Uaa-Token":    {"some-token"},
+			"X-Request-Id":   {"some-id"},
+			"Content-Length": {"12"},
+		}
+
+		Expect(fetcher.RedactHeaders(h)).To(Equal(http.Header{
+			"Authorization":  {"[REDACTED]"},
+			"X-Uaa-Token":    {"[REDACTED]"},
+			"X-Request-Id":   {"some-id"},
+			"Content-Length": {"12"},
+		}))
+		Expect(h.Get("Authorization")).To(Equal("Bearer some-token"))
+	})
+})
@@ -0,0 +1,120 @@
+package fetcher
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+)
+
+// DefaultConcurrency is the number of manifests a bulk fetch retrieves at
+// once when the Environment does not set Concurrency.
+const DefaultConcurrency = 4
+
+// ProductManifest is the outcome of fetching the manifest of one product in a
+// bulk fetch. Exactly one of Manifest and Err is set.
+type ProductManifest struct {
+	Product  Product
+	Manifest *bosh.Manifest
+	Err      error
+}
+
+// GetStagedProductManifests fetches the manifests of the staged products of
+// the given types, or of every staged product when no type is given. Results
+// follow the order of the product list, followed by requested types that are
+// not staged, which fail with the same error as GetStagedProductManifest.
+//
+// A product that cannot be fetched does not stop the others; only failing to
+// list the products is returned as an error. Timeout bounds the whole call.
+func (e *Environment) GetStagedProductManifests(types ...string) ([]ProductManifest, error) {
+	return e.GetStagedProductManifestsContext(context.Background(), types...)
+}
+
+func (e *Environment) GetStagedProductManifestsContext(ctx context.Context, types ...string) ([]ProductManifest, error) {
+	ctx, cancel := e.withTimeout(ctx)
+	defer cancel()
+
+	products, err := e.listProducts(ctx, staged)
+	if err != nil {
+		return nil, err
+	}
+
+	results := selectProducts(products, types)
+
+	var pending []int
+	for i, r := range results {
+		if r.Product.GUID == "" {
+			results[i].Err = notFound(staged, r.Product.Type)
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	e.fetchAll(ctx, staged, results, pending)
+	return results, nil
+}
+
+// selectProducts returns a result for every product of one of the types, or
+// for every product when there are no types. Unlike GetProductGUID it keeps
+// every product of a type, not only the first. Types no product has get a
+// result with only the Type set.
+func selectProducts(products Products, types []string) []ProductManifest {
+	var results []ProductManifest
+	if len(types) == 0 {
+		for _, p := range products {
+			results = append(results, ProductManifest{Product: p})
+		}
+		return results
+	}
+
+	wanted := map[string]bool{}
+	for _, t := range types {
+		wanted[t] = false
+	}
+
+	for _, p := range products {
+		if _, ok := wanted[p.Type]; ok {
+			results = append(results, ProductManifest{Product: p})
+			wanted[p.Type] = true
+		}
+	}
+
+	for _, t := range types {
+		if !wanted[t] {
+			results = append(results, ProductManifest{Product: Product{Type: t}})
+			wanted[t] = true
+		}
+	}
+	return results
+}
+
+// fetchAll fills in the manifests of the pending results with a pool of at
+// most Concurrency workers.
+func (e *Environment) fetchAll(ctx context.Context, scope string, results []ProductManifest, pending []int) {
+	workers := e.Concurrency
+	if workers <= 0 {
+		workers = DefaultConcurrency
+	}
+	if workers > len(pending) {
+		workers = len(pending)
+	}
+
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				r := &results[i]
+				r.Manifest, r.Err = e.productManifestByGUID(ctx, scope, r.Product.GUID)
+			}
+		}()
+	}
+
+	for _, i := range pending {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
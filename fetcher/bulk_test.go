@@ -0,0 +1,126 @@
+package fetcher_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/fetcher"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bulk fetches", func() {
+	var (
+		server            *httptest.Server
+		env               *fetcher.Environment
+		inFlight, busiest int32
+		listFails         bool
+	)
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&inFlight, 0)
+		atomic.StoreInt32(&busiest, 0)
+		listFails = false
+
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if strings.HasSuffix(req.URL.Path, "/manifest") {
+				n := atomic.AddInt32(&inFlight, 1)
+				defer atomic.AddInt32(&inFlight, -1)
+				for {
+					max := atomic.LoadInt32(&busiest)
+					if n <= max || atomic.CompareAndSwapInt32(&busiest, max, n) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+
+			switch req.URL.Path {
+			case "/uaa/oauth/token":
+				issueToken(w)
+			case "/api/v0/staged/products":
+				if listFails {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				w.Write([]byte(`[
+					{"type": "p-bosh", "guid": "p-bosh-guid", "installation_name": "p-bosh-guid", "product_version": "2.5.0"},
+					{"type": "cf", "guid": "cf-guid", "installation_name": "cf-guid", "product_version": "2.5.1"},
+					{"type": "p-mysql", "guid": "mysql-1", "installation_name": "mysql-1", "product_version": "2.6.0"},
+					{"type": "p-mysql", "guid": "mysql-2", "installation_name": "mysql-2", "product_version": "2.6.0"}
+				]`))
+			case "/api/v0/staged/products/p-bosh-guid/manifest":
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"errors": {"base": ["the director has no manifest"]}}`))
+			case "/api/v0/staged/products/cf-guid/manifest":
+				w.Write([]byte(`{"manifest": {"name": "cf"}}`))
+			case "/api/v0/staged/products/mysql-1/manifest":
+				w.Write([]byte(`{"manifest": {"name": "mysql-1"}}`))
+			case "/api/v0/staged/products/mysql-2/manifest":
+				w.Write([]byte(`{"manifest": {"name": "mysql-2"}}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		env = &fetcher.Environment{URL: server.URL, Username: "admin", Password: "secret", TLS: fetcher.TLSOptions{CACert: caCert(server)}}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("lists the staged products", func() {
+		products, err := env.ListStagedProducts()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(products).To(HaveLen(4))
+		Expect(products[1]).To(Equal(fetcher.Product{Type: "cf", GUID: "cf-guid", Version: "2.5.1", InstallationName: "cf-guid"}))
+	})
+
+	It("fetches the manifest of every staged product", func() {
+		results, err := env.GetStagedProductManifests()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(HaveLen(4))
+
+		Expect(results[0].Product.GUID).To(Equal("p-bosh-guid"))
+		Expect(results[0].Manifest).To(BeNil())
+		Expect(results[0].Err).To(MatchError("GET /api/v0/staged/products/p-bosh-guid/manifest: 404 Not Found: the director has no manifest"))
+
+		for i, name := range []string{"cf", "mysql-1", "mysql-2"} {
+			r := results[i+1]
+			Expect(r.Err).NotTo(HaveOccurred())
+			Expect(r.Manifest.Name).To(Equal(name))
+		}
+	})
+
+	It("fetches every product of the requested types", func() {
+		results, err := env.GetStagedProductManifests("p-mysql", "p-redis")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(HaveLen(3))
+
+		Expect(results[0].Manifest.Name).To(Equal("mysql-1"))
+		Expect(results[1].Manifest.Name).To(Equal("mysql-2"))
+		Expect(results[2].Product).To(Equal(fetcher.Product{Type: "p-redis"}))
+		Expect(results[2].Err).To(MatchError("could not find a product named p-redis"))
+	})
+
+	It("fetches at most Concurrency manifests at once", func() {
+		env.Concurrency = 2
+
+		_, err := env.GetStagedProductManifests()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(atomic.LoadInt32(&busiest)).To(Equal(int32(2)))
+	})
+
+	It("fails when the products cannot be listed", func() {
+		listFails = true
+
+		results, err := env.GetStagedProductManifests()
+		Expect(err).To(MatchError("GET /api/v0/staged/products: 403 Forbidden"))
+		Expect(results).To(BeNil())
+	})
+})
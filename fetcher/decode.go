@@ -0,0 +1,240 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// ErrNoManifest is returned, wrapped in a ManifestDecodeError, for an
+	// empty response or one without a manifest.
+	ErrNoManifest = errors.New("response contains no manifest")
+
+	// ErrDuplicateKey is returned, wrapped in a ManifestDecodeError, when
+	// the Environment is Strict and an object repeats a key.
+	ErrDuplicateKey = errors.New("duplicate key")
+)
+
+// ManifestDecodeError describes a manifest response that could not be
+// decoded. Line and Offset locate the problem in the response body when it is
+// known, and are zero and -1 otherwise.
+type ManifestDecodeError struct {
+	GUID   string
+	Line   int
+	Offset int64
+	Err    error
+}
+
+func (e *ManifestDecodeError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("could not decode manifest of product %s: %s", e.GUID, e.Err)
+	}
+	return fmt.Sprintf("could not decode manifest of product %s at line %d (byte %d): %s", e.GUID, e.Line, e.Offset, e.Err)
+}
+
+func (e *ManifestDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// decodeManifest checks a manifest response and decodes the manifest into
+// out, a *bosh.Manifest or a *yaml.MapSlice.
+func decodeManifest(guid string, b []byte, strict bool, out interface{}) error {
+	fail := func(offset int64, err error) error {
+		decodeErr := &ManifestDecodeError{GUID: guid, Offset: offset, Err: err}
+		if offset >= 0 {
+			decodeErr.Line = 1 + bytes.Count(b[:offset], []byte("\n"))
+		}
+		return decodeErr
+	}
+
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) == 0 {
+		return fail(-1, ErrNoManifest)
+	}
+
+	// Ops Manager answers with JSON, which gives precise offsets for syntax
+	// errors and duplicate keys. Other YAML is accepted too.
+	if trimmed[0] == '{' {
+		if offset, err := scanJSON(b, strict); err != nil {
+			return fail(offset, err)
+		}
+	}
+
+	response := struct {
+		Manifest yaml.MapSlice `yaml:"manifest"`
+	}{}
+	if err := yaml.Unmarshal(b, &response); err != nil {
+		return fail(yamlErrorOffset(b, err), err)
+	}
+
+	if response.Manifest == nil {
+		return fail(-1, ErrNoManifest)
+	}
+
+	if strict && trimmed[0] != '{' {
+		if path, key, found := duplicateKey(response.Manifest, "manifest"); found {
+			return fail(-1, fmt.Errorf("%w %q in %s", ErrDuplicateKey, key, path))
+		}
+	}
+
+	switch target := out.(type) {
+	case *yaml.MapSlice:
+		*target = response.Manifest
+		return nil
+	case *bosh.Manifest:
+		r := bosh.StagedManifestResponse{Manifest: target}
+		if err := yaml.Unmarshal(b, &r); err != nil {
+			return fail(yamlErrorOffset(b, err), err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("cannot decode a manifest into %T", out)
+}
+
+// scanJSON walks a JSON document, returning the offset of the first syntax
+// error or, when strict, of the first repeated key.
+func scanJSON(b []byte, strict bool) (int64, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+
+	offset, err := scanJSONValue(dec, b, "", strict)
+	if err != nil {
+		return offset, err
+	}
+
+	if dec.More() {
+		return dec.InputOffset(), errors.New("unexpected data after the response")
+	}
+	return 0, nil
+}
+
+func scanJSONValue(dec *json.Decoder, b []byte, path string, strict bool) (int64, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return jsonErrorOffset(dec, b, err), err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		seen := map[string]bool{}
+		for dec.More() {
+			keyOffset := dec.InputOffset()
+			if quote := bytes.IndexByte(b[keyOffset:], '"'); quote >= 0 {
+				keyOffset += int64(quote)
+			}
+
+			tok, err := dec.Token()
+			if err != nil {
+				return jsonErrorOffset(dec, b, err), err
+			}
+
+			key := tok.(string)
+			child := joinPath(path, key)
+			if strict && seen[key] {
+				return keyOffset, fmt.Errorf("%w %q in %s", ErrDuplicateKey, key, pathOrRoot(path))
+			}
+			seen[key] = true
+
+			if offset, err := scanJSONValue(dec, b, child, strict); err != nil {
+				return offset, err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return jsonErrorOffset(dec, b, err), err
+		}
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			if offset, err := scanJSONValue(dec, b, fmt.Sprintf("%s[%d]", path, i), strict); err != nil {
+				return offset, err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return jsonErrorOffset(dec, b, err), err
+		}
+	}
+
+	return 0, nil
+}
+
+func jsonErrorOffset(dec *json.Decoder, b []byte, err error) int64 {
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &syntaxErr):
+		// Offset counts the bytes read, including the offending one.
+		return syntaxErr.Offset - 1
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return int64(len(b))
+	}
+	return dec.InputOffset()
+}
+
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+// yamlErrorOffset returns the offset of the start of the line a yaml error
+// refers to, or -1 when it names none.
+func yamlErrorOffset(b []byte, err error) int64 {
+	match := yamlErrorLine.FindStringSubmatch(err.Error())
+	if match == nil {
+		return -1
+	}
+
+	line, _ := strconv.Atoi(match[1])
+	offset := 0
+	for n := 1; n < line; n++ {
+		next := bytes.IndexByte(b[offset:], '\n')
+		if next < 0 {
+			return -1
+		}
+		offset += next + 1
+	}
+	return int64(offset)
+}
+
+// duplicateKey finds the first key repeated within a map of v.
+func duplicateKey(v interface{}, path string) (string, string, bool) {
+	switch value := v.(type) {
+	case yaml.MapSlice:
+		seen := map[string]bool{}
+		for _, item := range value {
+			key := fmt.Sprint(item.Key)
+			if seen[key] {
+				return path, key, true
+			}
+			seen[key] = true
+
+			if p, k, found := duplicateKey(item.Value, joinPath(path, key)); found {
+				return p, k, true
+			}
+		}
+	case []interface{}:
+		for i, e := range value {
+			if p, k, found := duplicateKey(e, fmt.Sprintf("%s[%d]", path, i)); found {
+				return p, k, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "the response"
+	}
+	return path
+}
@@ -0,0 +1,110 @@
+package fetcher_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/fetcher"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Decoding manifest responses", func() {
+	var (
+		server *httptest.Server
+		env    *fetcher.Environment
+		body   string
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/uaa/oauth/token":
+				issueToken(w)
+			case "/api/v0/staged/products":
+				w.Write([]byte(`[{"type": "cf", "guid": "cf-guid"}]`))
+			default:
+				w.Write([]byte(body))
+			}
+		}))
+
+		env = &fetcher.Environment{URL: server.URL, Username: "admin", Password: "secret", TLS: fetcher.TLSOptions{CACert: caCert(server)}}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	DescribeTable("malformed responses",
+		func(response, message string) {
+			body = response
+
+			_, err := env.GetStagedProductManifestByGUID("cf-guid")
+			Expect(err).To(MatchError(message))
+
+			var decodeErr *fetcher.ManifestDecodeError
+			Expect(errors.As(err, &decodeErr)).To(BeTrue())
+			Expect(decodeErr.GUID).To(Equal("cf-guid"))
+
+			_, err = env.GetRawStagedProductManifest("cf")
+			Expect(err).To(MatchError(message))
+		},
+		Entry("empty", "",
+			"could not decode manifest of product cf-guid: response contains no manifest"),
+		Entry("without a manifest", `{"errors": []}`,
+			"could not decode manifest of product cf-guid: response contains no manifest"),
+		Entry("with a null manifest", `{"manifest": null}`,
+			"could not decode manifest of product cf-guid: response contains no manifest"),
+		Entry("truncated", "{\"manifest\": {\n  \"name\": \"cf\"",
+			"could not decode manifest of product cf-guid at line 2 (byte 28): unexpected end of JSON input"),
+		Entry("with invalid JSON", "{\"manifest\": {\n  \"name\": cf}}",
+			"could not decode manifest of product cf-guid at line 2 (byte 25): invalid character 'c' looking for beginning of value"),
+	)
+
+	It("reports where a manifest of the wrong shape fails to decode", func() {
+		body = "manifest:\n  name: cf\n  instance_groups: nope\n"
+
+		_, err := env.GetStagedProductManifestByGUID("cf-guid")
+		Expect(err).To(MatchError("could not decode manifest of product cf-guid at line 3 (byte 21): yaml: unmarshal errors:\n  line 3: cannot unmarshal !!str `nope` into []*bosh.InstanceGroup"))
+	})
+
+	It("keeps the last value of duplicate keys by default", func() {
+		body = `{"manifest": {"name": "first", "name": "second"}}`
+
+		manifest, err := env.GetStagedProductManifestByGUID("cf-guid")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifest.Name).To(Equal("second"))
+	})
+
+	Context("when strict", func() {
+		BeforeEach(func() {
+			env.Strict = true
+		})
+
+		It("rejects duplicate keys in JSON with their location", func() {
+			body = "{\"manifest\": {\n  \"instance_groups\": [{\"name\": \"router\", \"name\": \"nats\"}]}}"
+
+			_, err := env.GetStagedProductManifestByGUID("cf-guid")
+			Expect(errors.Is(err, fetcher.ErrDuplicateKey)).To(BeTrue())
+			Expect(err).To(MatchError(`could not decode manifest of product cf-guid at line 2 (byte 56): duplicate key "name" in manifest.instance_groups[0]`))
+		})
+
+		It("rejects duplicate keys in YAML", func() {
+			body = "manifest:\n  name: cf\n  name: cf2\n"
+
+			_, err := env.GetRawStagedProductManifest("cf")
+			Expect(err).To(MatchError(`could not decode manifest of product cf-guid: duplicate key "name" in manifest`))
+		})
+
+		It("accepts manifests without duplicates", func() {
+			body = `{"manifest": {"name": "cf", "instance_groups": [{"name": "router"}, {"name": "nats"}]}}`
+
+			raw, err := env.GetRawStagedProductManifest("cf")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(raw)).To(Equal("name: cf\ninstance_groups:\n- name: router\n- name: nats\n"))
+		})
+	})
+})
@@ -0,0 +1,54 @@
+package fetcher
+
+import (
+	"context"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+)
+
+// The Deployed counterparts of the staged calls read what the last Apply
+// Changes deployed, so it can be validated or compared with what is staged.
+// A product that has never been deployed is not found.
+
+func (e *Environment) GetDeployedProductManifest(name string) (*bosh.Manifest, error) {
+	return e.GetDeployedProductManifestContext(context.Background(), name)
+}
+
+func (e *Environment) GetDeployedProductManifestContext(ctx context.Context, name string) (*bosh.Manifest, error) {
+	return e.productManifest(ctx, deployed, name)
+}
+
+func (e *Environment) GetDeployedProductGUID(name string) (string, error) {
+	return e.GetDeployedProductGUIDContext(context.Background(), name)
+}
+
+func (e *Environment) GetDeployedProductGUIDContext(ctx context.Context, name string) (string, error) {
+	return e.productGUID(ctx, deployed, name)
+}
+
+func (e *Environment) GetDeployedProductManifestByGUID(guid string) (*bosh.Manifest, error) {
+	return e.GetDeployedProductManifestByGUIDContext(context.Background(), guid)
+}
+
+func (e *Environment) GetDeployedProductManifestByGUIDContext(ctx context.Context, guid string) (*bosh.Manifest, error) {
+	return e.productManifestByGUID(ctx, deployed, guid)
+}
+
+func (e *Environment) GetRawDeployedProductManifest(name string) ([]byte, error) {
+	return e.GetRawDeployedProductManifestContext(context.Background(), name)
+}
+
+func (e *Environment) GetRawDeployedProductManifestContext(ctx context.Context, name string) ([]byte, error) {
+	return e.rawProductManifest(ctx, deployed, name)
+}
+
+func (e *Environment) ListDeployedProducts() (Products, error) {
+	return e.ListDeployedProductsContext(context.Background())
+}
+
+func (e *Environment) ListDeployedProductsContext(ctx context.Context) (Products, error) {
+	ctx, cancel := e.withTimeout(ctx)
+	defer cancel()
+
+	return e.listProducts(ctx, deployed)
+}
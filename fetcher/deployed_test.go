@@ -0,0 +1,72 @@
+package fetcher_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/fetcher"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Deployed products", func() {
+	var (
+		server *httptest.Server
+		env    *fetcher.Environment
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/uaa/oauth/token":
+				issueToken(w)
+			case "/api/v0/staged/products":
+				w.Write([]byte(`[{"type": "cf", "guid": "cf-staged-guid"}, {"type": "p-mysql", "guid": "mysql-guid"}]`))
+			case "/api/v0/deployed/products":
+				w.Write([]byte(`[{"type": "cf", "guid": "cf-deployed-guid", "installation_name": "cf-deployed-guid", "product_version": "2.0.0"}]`))
+			case "/api/v0/deployed/products/cf-deployed-guid/manifest":
+				w.Write([]byte(`{"manifest": {"name": "cf", "instance_groups": [{"name": "router", "instances": 2}]}}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		env = &fetcher.Environment{URL: server.URL, Username: "admin", Password: "secret", TLS: fetcher.TLSOptions{CACert: caCert(server)}}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("lists the deployed products", func() {
+		products, err := env.ListDeployedProducts()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(products).To(Equal(fetcher.Products{
+			{Type: "cf", GUID: "cf-deployed-guid", InstallationName: "cf-deployed-guid", Version: "2.0.0"},
+		}))
+	})
+
+	It("finds the GUID in the deployed products", func() {
+		guid, err := env.GetDeployedProductGUID("cf")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(guid).To(Equal("cf-deployed-guid"))
+	})
+
+	It("fetches the deployed manifest", func() {
+		manifest, err := env.GetDeployedProductManifest("cf")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifest.MustFindInstanceGroupNamed("router").Instances()).To(Equal(2))
+	})
+
+	It("fetches the raw deployed manifest", func() {
+		raw, err := env.GetRawDeployedProductManifest("cf")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(raw)).To(Equal("name: cf\ninstance_groups:\n- name: router\n  instances: 2\n"))
+	})
+
+	It("returns an error for products that are only staged", func() {
+		_, err := env.GetDeployedProductManifest("p-mysql")
+		Expect(err).To(MatchError("could not find a deployed product named p-mysql"))
+	})
+})
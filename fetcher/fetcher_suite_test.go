@@ -4,6 +4,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"net/http"
 	"testing"
 )
 
@@ -11,3 +12,10 @@ func TestFetcher(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Fetcher Suite")
 }
+
+// issueToken answers a UAA token request the way the fake Ops Manager servers
+// of these tests do.
+func issueToken(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"access_token": "some-token", "token_type": "Bearer", "expires_in": 3600}`))
+}
@@ -1,11 +1,13 @@
 package fetcher
 
 import (
-	"errors"
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
-	"net/http/httputil"
+	"sync"
+	"time"
 
 	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
 
@@ -14,136 +16,283 @@ import (
 
 type Products []Product
 
+// Product is an entry of the product list of Ops Manager. Version is the
+// version of the product tile and InstallationName the name of its BOSH
+// deployment.
 type Product struct {
-	Type string `yaml:"type"`
-	GUID string `yaml:"guid"`
+	Type             string `yaml:"type"`
+	GUID             string `yaml:"guid"`
+	Version          string `yaml:"product_version"`
+	InstallationName string `yaml:"installation_name"`
 }
 
+// Environment is an Ops Manager to fetch manifests from. It authenticates
+// once and reuses its client, so use it through a pointer and do not copy it
+// after the first request.
+//
+// Without a Username, ClientID and ClientSecret are used for the
+// client_credentials grant. With one, they replace the default opsman client
+// of the password grant.
+//
+// Timeout bounds each call, however many requests and retries it makes, and
+// RequestTimeout bounds every single HTTP request, including those to UAA.
+// Zero means no timeout. Failed requests are retried according to Retry,
+// which defaults to DefaultRetryPolicy. When Strict is set, manifests that
+// repeat a key are rejected rather than keeping the last value. Concurrency
+// limits how many manifests a bulk fetch retrieves at once and defaults to
+// DefaultConcurrency.
 type Environment struct {
-	URL      string
-	Username string
-	Password string
+	URL            string
+	Username       string
+	Password       string
+	ClientID       string
+	ClientSecret   string
+	TLS            TLSOptions
+	Timeout        time.Duration
+	RequestTimeout time.Duration
+	Retry          RetryPolicy
+	Strict         bool
+	Concurrency    int
+
+	mu     sync.Mutex
+	client *http.Client
 }
 
-func (e Environment) GetStagedProductManifest(name string) (*bosh.Manifest, error) {
-	guid, err := e.GetProductGUID(name)
+// Products are either staged, pending the next Apply Changes, or deployed.
+const (
+	staged   = "staged"
+	deployed = "deployed"
+)
+
+func (e *Environment) GetStagedProductManifest(name string) (*bosh.Manifest, error) {
+	return e.GetStagedProductManifestContext(context.Background(), name)
+}
+
+func (e *Environment) GetStagedProductManifestContext(ctx context.Context, name string) (*bosh.Manifest, error) {
+	return e.productManifest(ctx, staged, name)
+}
+
+func (e *Environment) GetProductGUID(name string) (string, error) {
+	return e.GetProductGUIDContext(context.Background(), name)
+}
+
+func (e *Environment) GetProductGUIDContext(ctx context.Context, name string) (string, error) {
+	return e.productGUID(ctx, staged, name)
+}
+
+func (e *Environment) GetStagedProductManifestByGUID(guid string) (*bosh.Manifest, error) {
+	return e.GetStagedProductManifestByGUIDContext(context.Background(), guid)
+}
+
+func (e *Environment) GetStagedProductManifestByGUIDContext(ctx context.Context, guid string) (*bosh.Manifest, error) {
+	return e.productManifestByGUID(ctx, staged, guid)
+}
+
+func (e *Environment) GetRawStagedProductManifest(name string) ([]byte, error) {
+	return e.GetRawStagedProductManifestContext(context.Background(), name)
+}
+
+func (e *Environment) GetRawStagedProductManifestContext(ctx context.Context, name string) ([]byte, error) {
+	return e.rawProductManifest(ctx, staged, name)
+}
+
+func (e *Environment) productManifest(ctx context.Context, scope, name string) (*bosh.Manifest, error) {
+	ctx, cancel := e.withTimeout(ctx)
+	defer cancel()
+
+	guid, err := e.productGUID(ctx, scope, name)
 	if err != nil {
 		return nil, err
 	}
 
-	return e.GetStagedProductManifestByGUID(guid)
+	return e.productManifestByGUID(ctx, scope, guid)
 }
 
-func (e Environment) GetProductGUID(name string) (string, error) {
-	var productGUID string
+func (e *Environment) ListStagedProducts() (Products, error) {
+	return e.ListStagedProductsContext(context.Background())
+}
 
-	client, err := e.oauthClient()
-	if err != nil {
-		return "", err
-	}
+func (e *Environment) ListStagedProductsContext(ctx context.Context) (Products, error) {
+	ctx, cancel := e.withTimeout(ctx)
+	defer cancel()
 
-	req, err := http.NewRequest("GET", e.URL+"/api/v0/staged/products", nil)
-	if err != nil {
-		return "", err
-	}
+	return e.listProducts(ctx, staged)
+}
 
-	res, err := client.Do(req)
+func (e *Environment) listProducts(ctx context.Context, scope string) (Products, error) {
+	b, err := e.get(ctx, "/api/v0/"+scope+"/products")
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	if res.StatusCode != http.StatusOK {
-		b, _ := httputil.DumpResponse(res, true)
-		return "", errors.New("error getting manifest: " + string(b))
+	ps := Products{}
+	if err := yaml.Unmarshal(b, &ps); err != nil {
+		return nil, err
 	}
 
-	b, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return "", err
-	}
+	return ps, nil
+}
+
+func (e *Environment) productGUID(ctx context.Context, scope, name string) (string, error) {
+	var productGUID string
 
-	ps := &Products{}
-	err = yaml.Unmarshal(b, ps)
+	ctx, cancel := e.withTimeout(ctx)
+	defer cancel()
+
+	ps, err := e.listProducts(ctx, scope)
 	if err != nil {
 		return "", err
 	}
 
-	for _, p := range *ps {
+	for _, p := range ps {
 		if p.Type == name {
 			productGUID = p.GUID
 			break
 		}
 	}
 	if productGUID == "" {
-		return "", fmt.Errorf("could not find a product named %s", name)
+		return "", notFound(scope, name)
 	}
 
 	return productGUID, nil
 }
 
-func (e Environment) GetStagedProductManifestByGUID(guid string) (*bosh.Manifest, error) {
-	b, err := e.makeRequest(guid)
+func notFound(scope, name string) error {
+	if scope == deployed {
+		return fmt.Errorf("could not find a deployed product named %s", name)
+	}
+	return fmt.Errorf("could not find a product named %s", name)
+}
+
+func (e *Environment) productManifestByGUID(ctx context.Context, scope, guid string) (*bosh.Manifest, error) {
+	ctx, cancel := e.withTimeout(ctx)
+	defer cancel()
+
+	b, err := e.makeRequest(ctx, scope, guid)
 	if err != nil {
 		return nil, err
 	}
 
-	r := &bosh.StagedManifestResponse{}
-	yaml.Unmarshal(b, r)
+	manifest := &bosh.Manifest{}
+	if err := decodeManifest(guid, b, e.Strict, manifest); err != nil {
+		return nil, err
+	}
 
-	return r.Manifest, nil
+	return manifest, nil
 }
 
-func (e Environment) GetRawStagedProductManifest(name string) ([]byte, error) {
-	guid, err := e.GetProductGUID(name)
+func (e *Environment) rawProductManifest(ctx context.Context, scope, name string) ([]byte, error) {
+	ctx, cancel := e.withTimeout(ctx)
+	defer cancel()
+
+	guid, err := e.productGUID(ctx, scope, name)
 	if err != nil {
 		return nil, err
 	}
 
-	b, err := e.makeRequest(guid)
+	b, err := e.makeRequest(ctx, scope, guid)
 	if err != nil {
 		return nil, err
 	}
 
-	var manifestWrapper map[string]interface{}
-	yaml.Unmarshal(b, &manifestWrapper)
-
-	manifest, err := yaml.Marshal(manifestWrapper["manifest"].(map[interface {}]interface {}))
-	if err != nil {
-		panic(err)
+	manifest := yaml.MapSlice{}
+	if err := decodeManifest(guid, b, e.Strict, &manifest); err != nil {
+		return nil, err
 	}
 
-	return manifest, nil
+	return yaml.Marshal(manifest)
+}
+
+func (e *Environment) makeRequest(ctx context.Context, scope, guid string) ([]byte, error) {
+	return e.get(ctx, "/api/v0/"+scope+"/products/"+guid+"/manifest")
 }
 
-func (e Environment) makeRequest(guid string) ([]byte, error) {
-	client, err := e.oauthClient()
+// get fetches an API endpoint, retrying according to the Retry policy.
+func (e *Environment) get(ctx context.Context, path string) ([]byte, error) {
+	client, err := e.oauthClient(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("GET", e.URL+"/api/v0/staged/products/"+guid+"/manifest", nil)
-	if err != nil {
-		return nil, err
+	policy := e.Retry.orDefault()
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequest("GET", e.URL+path, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := client.Do(req.WithContext(ctx))
+		if attempt < policy.MaxAttempts && ctx.Err() == nil && shouldRetry(res, err) {
+			if delay := policy.delay(attempt, res); fitsDeadline(ctx, delay) {
+				if res != nil {
+					io.Copy(ioutil.Discard, res.Body)
+					res.Body.Close()
+				}
+				if err := sleep(ctx, delay); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return readResponse(res)
 	}
+}
 
-	res, err := client.Do(req)
+func readResponse(res *http.Response) ([]byte, error) {
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
 	if err != nil {
 		return nil, err
 	}
 
 	if res.StatusCode != http.StatusOK {
-		b, _ := httputil.DumpResponse(res, true)
-		return nil, errors.New("error getting manifest: " + string(b))
+		return nil, newAPIError(res, b)
 	}
 
-	b, err := ioutil.ReadAll(res.Body)
+	return b, nil
+}
+
+// withTimeout applies the overall Timeout. Calls made on behalf of another
+// call cannot extend the deadline of the outer one.
+func (e *Environment) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if e.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, e.Timeout)
+}
+
+func (e *Environment) oauthClient(ctx context.Context) (*http.Client, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.client != nil {
+		return e.client, nil
+	}
+
+	client, err := NewOAuthHTTPClientContext(ctx, e.URL+"/uaa", e.Username, e.Password, e.clientOptions()...)
 	if err != nil {
 		return nil, err
 	}
 
-	return b, nil
+	e.client = client
+	return client, nil
 }
 
-func (e Environment) oauthClient() (*http.Client, error) {
-	return NewOAuthHTTPClient(e.URL+"/uaa", e.Username, e.Password)
+func (e *Environment) clientOptions() []ClientOption {
+	opts := []ClientOption{WithTLS(e.TLS), WithRequestTimeout(e.RequestTimeout)}
+
+	switch {
+	case e.ClientID == "":
+	case e.Username == "":
+		opts = append(opts, WithClientCredentials(e.ClientID, e.ClientSecret))
+	default:
+		opts = append(opts, WithClient(e.ClientID, e.ClientSecret))
+	}
+
+	return opts
 }
@@ -0,0 +1,122 @@
+package fetcher_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/fetcher"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Environment", func() {
+	var (
+		server        *httptest.Server
+		tokenRequests int32
+		grantType     string
+	)
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&tokenRequests, 0)
+
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/uaa/oauth/token":
+				atomic.AddInt32(&tokenRequests, 1)
+				Expect(req.ParseForm()).To(Succeed())
+				grantType = req.PostForm.Get("grant_type")
+				issueToken(w)
+			case "/api/v0/staged/products":
+				w.Write([]byte(`[{"type": "cf", "guid": "cf-guid"}]`))
+			case "/api/v0/staged/products/cf-guid/manifest":
+				w.Write([]byte(`{"manifest": {"name": "cf-guid"}}`))
+			case "/api/v0/staged/products/slow-guid/manifest":
+				select {
+				case <-req.Context().Done():
+				case <-time.After(5 * time.Second):
+				}
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("authenticates once and reuses its client", func() {
+		env := &fetcher.Environment{URL: server.URL, Username: "admin", Password: "secret", TLS: fetcher.TLSOptions{CACert: caCert(server)}}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				manifest, err := env.GetStagedProductManifest("cf")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(manifest.Name).To(Equal("cf-guid"))
+			}()
+		}
+		wg.Wait()
+
+		Expect(atomic.LoadInt32(&tokenRequests)).To(Equal(int32(1)))
+	})
+
+	It("authenticates with client credentials when no username is given", func() {
+		env := &fetcher.Environment{URL: server.URL, ClientID: "ci-client", ClientSecret: "ci-secret", TLS: fetcher.TLSOptions{CACert: caCert(server)}}
+
+		_, err := env.GetProductGUID("cf")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(grantType).To(Equal("client_credentials"))
+	})
+
+	Context("with contexts and timeouts", func() {
+		var env *fetcher.Environment
+
+		BeforeEach(func() {
+			env = &fetcher.Environment{URL: server.URL, Username: "admin", Password: "secret", TLS: fetcher.TLSOptions{CACert: caCert(server)}}
+		})
+
+		It("stops when the context is cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			_, err := env.GetStagedProductManifestContext(ctx, "cf")
+			Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+		})
+
+		It("gives up once the overall timeout has passed", func() {
+			env.Timeout = 100 * time.Millisecond
+
+			start := time.Now()
+			_, err := env.GetStagedProductManifestByGUID("slow-guid")
+			Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		})
+
+		It("gives up once a request has taken too long", func() {
+			env.RequestTimeout = 100 * time.Millisecond
+
+			_, err := env.GetStagedProductManifestByGUID("slow-guid")
+			Expect(err).To(MatchError(ContainSubstring("Client.Timeout exceeded")))
+		})
+
+		It("still works within the timeouts", func() {
+			env.Timeout = 5 * time.Second
+			env.RequestTimeout = 5 * time.Second
+
+			manifest, err := env.GetStagedProductManifest("cf")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manifest.Name).To(Equal("cf-guid"))
+		})
+	})
+})
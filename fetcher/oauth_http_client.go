@@ -1,38 +1,216 @@
 package fetcher
 
 import (
-	"crypto/tls"
 	"fmt"
 	"net/http"
+	"time"
 
 	"context"
 	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
 )
 
-func NewOAuthHTTPClient(host, username, password string) (*http.Client, error) {
+const defaultClientID = "opsman"
+
+type clientOptions struct {
+	clientID          string
+	clientSecret      string
+	clientCredentials bool
+	tls               TLSOptions
+	requestTimeout    time.Duration
+}
+
+// ClientOption configures how NewOAuthHTTPClient authenticates against UAA.
+type ClientOption func(*clientOptions)
+
+// WithClient sets the UAA client used for the password grant, instead of the
+// public opsman client.
+func WithClient(clientID, clientSecret string) ClientOption {
+	return func(o *clientOptions) {
+		o.clientID = clientID
+		o.clientSecret = clientSecret
+	}
+}
+
+// WithClientCredentials authenticates as the UAA client itself using the
+// client_credentials grant. The username and password are ignored.
+func WithClientCredentials(clientID, clientSecret string) ClientOption {
+	return func(o *clientOptions) {
+		o.clientID = clientID
+		o.clientSecret = clientSecret
+		o.clientCredentials = true
+	}
+}
+
+// WithTLS configures certificate verification for both UAA and the API
+// requests made with the client.
+func WithTLS(tls TLSOptions) ClientOption {
+	return func(o *clientOptions) {
+		o.tls = tls
+	}
+}
+
+// WithRequestTimeout bounds every request made with the client, including the
+// token requests to UAA. Zero means no timeout.
+func WithRequestTimeout(timeout time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.requestTimeout = timeout
+	}
+}
+
+func NewOAuthHTTPClient(host, username, password string, opts ...ClientOption) (*http.Client, error) {
+	return NewOAuthHTTPClientContext(context.Background(), host, username, password, opts...)
+}
+
+// NewOAuthHTTPClientContext is like NewOAuthHTTPClient, using ctx to fetch
+// the first token.
+func NewOAuthHTTPClientContext(ctx context.Context, host, username, password string, opts ...ClientOption) (*http.Client, error) {
+	options := clientOptions{clientID: defaultClientID}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	tokenURL := fmt.Sprintf("%s/oauth/token", host)
+
 	conf := &oauth2.Config{
-		ClientID:     "opsman",
-		ClientSecret: "",
+		ClientID:     options.clientID,
+		ClientSecret: options.clientSecret,
 		Endpoint: oauth2.Endpoint{
-			TokenURL: fmt.Sprintf("%s/oauth/token", host),
+			TokenURL: tokenURL,
 		},
 	}
 
+	tlsConfig, err := options.tls.Config()
+	if err != nil {
+		return nil, err
+	}
+
 	httpclient := &http.Client{
 		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
+			TLSClientConfig: tlsConfig,
 		},
+		Timeout: options.requestTimeout,
+	}
+
+	source := &tokenSource{
+		client: httpclient,
+		config: conf,
+		lock:   make(chan struct{}, 1),
+		grant: func(ctx context.Context) (*oauth2.Token, error) {
+			return conf.PasswordCredentialsToken(ctx, username, password)
+		},
+	}
+
+	if options.clientCredentials {
+		clientConf := &clientcredentials.Config{
+			ClientID:     options.clientID,
+			ClientSecret: options.clientSecret,
+			TokenURL:     tokenURL,
+		}
+		// The vendored oauth2 takes golang.org/x/net/context, so the method
+		// value does not fit grant directly.
+		source.grant = func(ctx context.Context) (*oauth2.Token, error) {
+			return clientConf.Token(ctx)
+		}
 	}
 
-	insecureContext := context.Background()
-	insecureContext = context.WithValue(insecureContext, oauth2.HTTPClient, httpclient)
+	// Fetch the first token now so bad credentials are reported here rather
+	// than by the first request.
+	if _, err := source.TokenContext(ctx); err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Transport: &tokenTransport{
+			source: source,
+			base:   httpclient.Transport,
+		},
+		Timeout: options.requestTimeout,
+	}, nil
+}
+
+// tokenTransport authorizes requests like oauth2.Transport, but renews the
+// token with the context of the request so that cancelling the request also
+// abandons a renewal that hangs.
+type tokenTransport struct {
+	source *tokenSource
+	base   http.RoundTripper
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.source.TokenContext(req.Context())
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	authorized := req.WithContext(req.Context())
+	authorized.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		authorized.Header[k] = v
+	}
+	token.SetAuthHeader(authorized)
+
+	return t.base.RoundTrip(authorized)
+}
+
+// contextTransport attaches ctx to every request. The vendored oauth2 does
+// not pass the context of a token request on to the HTTP request it makes.
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
+
+// tokenSource caches a UAA token and is safe for concurrent use. Shortly
+// before the token expires it is renewed with its refresh token, falling back
+// to a new grant when there is none or the refresh fails. lock holds a value
+// while the token is read or renewed; unlike a mutex, waiting for it can be
+// cancelled.
+type tokenSource struct {
+	client *http.Client
+	config *oauth2.Config
+	grant  func(context.Context) (*oauth2.Token, error)
+
+	lock  chan struct{}
+	token *oauth2.Token
+}
+
+func (s *tokenSource) TokenContext(ctx context.Context) (*oauth2.Token, error) {
+	select {
+	case s.lock <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-s.lock }()
+
+	if s.token.Valid() {
+		return s.token, nil
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{
+		Transport: contextTransport{ctx: ctx, base: s.client.Transport},
+		Timeout:   s.client.Timeout,
+	})
+
+	if s.token != nil && s.token.RefreshToken != "" {
+		token, err := s.config.TokenSource(ctx, s.token).Token()
+		if err == nil {
+			s.token = token
+			return token, nil
+		}
+	}
 
-	token, err := conf.PasswordCredentialsToken(insecureContext, username, password)
+	token, err := s.grant(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	return conf.Client(insecureContext, token), nil
+	s.token = token
+	return token, nil
 }
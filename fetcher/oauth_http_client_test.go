@@ -1,9 +1,12 @@
 package fetcher_test
 
 import (
+	"context"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"time"
 
 	"github.com/pivotal-cf-experimental/om-manifest-validator/fetcher"
 
@@ -38,7 +41,7 @@ var _ = Describe("NewOAuthHTTPClient", func() {
 			}`))
 		}))
 
-		client, err := fetcher.NewOAuthHTTPClient(oauthServer.URL, "opsman-username", "opsman-password")
+		client, err := fetcher.NewOAuthHTTPClient(oauthServer.URL, "opsman-username", "opsman-password", trust(oauthServer))
 		Expect(err).NotTo(HaveOccurred())
 
 		var wasCalled bool
@@ -55,6 +58,146 @@ var _ = Describe("NewOAuthHTTPClient", func() {
 		Expect(wasCalled).To(BeTrue())
 	})
 
+	Context("with client options", func() {
+		var (
+			form   url.Values
+			server *httptest.Server
+		)
+
+		BeforeEach(func() {
+			server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				Expect(req.URL.Path).To(Equal("/oauth/token"))
+				username, password, ok := req.BasicAuth()
+				Expect(ok).To(BeTrue())
+				Expect(username).To(Equal("ci-client"))
+				Expect(password).To(Equal("ci-secret"))
+
+				Expect(req.ParseForm()).To(Succeed())
+				form = req.PostForm
+
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"access_token": "some-token", "token_type": "Bearer", "expires_in": 3600}`))
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("uses the client_credentials grant", func() {
+			_, err := fetcher.NewOAuthHTTPClient(server.URL, "", "", trust(server), fetcher.WithClientCredentials("ci-client", "ci-secret"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(form.Get("grant_type")).To(Equal("client_credentials"))
+			Expect(form).NotTo(HaveKey("username"))
+		})
+
+		It("uses the given client for the password grant", func() {
+			_, err := fetcher.NewOAuthHTTPClient(server.URL, "opsman-username", "opsman-password", trust(server), fetcher.WithClient("ci-client", "ci-secret"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(form.Get("grant_type")).To(Equal("password"))
+			Expect(form.Get("username")).To(Equal("opsman-username"))
+		})
+	})
+
+	Context("when the token expires", func() {
+		var (
+			grants       []string
+			refreshFails bool
+			refreshHangs chan struct{}
+			server       *httptest.Server
+		)
+
+		BeforeEach(func() {
+			grants = nil
+			refreshFails = false
+			refreshHangs = nil
+
+			server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Path != "/oauth/token" {
+					w.Write([]byte(req.Header.Get("Authorization")))
+					return
+				}
+
+				Expect(req.ParseForm()).To(Succeed())
+				grant := req.Form.Get("grant_type")
+				grants = append(grants, grant)
+
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case grant == "refresh_token" && refreshHangs != nil:
+					<-refreshHangs
+				case grant == "refresh_token" && refreshFails:
+					w.WriteHeader(http.StatusUnauthorized)
+				case grant == "refresh_token":
+					Expect(req.Form.Get("refresh_token")).To(Equal("some-refresh-token"))
+					w.Write([]byte(`{"access_token": "refreshed-token", "token_type": "Bearer", "expires_in": 3600}`))
+				default:
+					// Tokens expiring within seconds are renewed before use.
+					w.Write([]byte(`{"access_token": "first-token", "refresh_token": "some-refresh-token", "token_type": "Bearer", "expires_in": 1}`))
+				}
+			}))
+		})
+
+		AfterEach(func() {
+			if refreshHangs != nil {
+				close(refreshHangs)
+			}
+			server.Close()
+		})
+
+		get := func(client *http.Client) string {
+			res, err := client.Get(server.URL + "/api")
+			Expect(err).NotTo(HaveOccurred())
+			defer res.Body.Close()
+
+			b, err := ioutil.ReadAll(res.Body)
+			Expect(err).NotTo(HaveOccurred())
+			return string(b)
+		}
+
+		It("refreshes the token with the refresh token and caches the result", func() {
+			client, err := fetcher.NewOAuthHTTPClient(server.URL, "opsman-username", "opsman-password", trust(server))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(get(client)).To(Equal("Bearer refreshed-token"))
+			Expect(get(client)).To(Equal("Bearer refreshed-token"))
+			Expect(grants).To(Equal([]string{"password", "refresh_token"}))
+		})
+
+		It("falls back to the password grant when the refresh fails", func() {
+			refreshFails = true
+
+			client, err := fetcher.NewOAuthHTTPClient(server.URL, "opsman-username", "opsman-password", trust(server))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(get(client)).To(Equal("Bearer first-token"))
+			Expect(grants).To(Equal([]string{"password", "refresh_token", "password"}))
+		})
+
+		It("gives up on a refresh that hangs when the request is cancelled", func() {
+			refreshHangs = make(chan struct{})
+
+			client, err := fetcher.NewOAuthHTTPClient(server.URL, "opsman-username", "opsman-password", trust(server))
+			Expect(err).NotTo(HaveOccurred())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			req, err := http.NewRequest("GET", server.URL+"/api", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			errs := make(chan error, 1)
+			go func() {
+				_, err := client.Do(req.WithContext(ctx))
+				errs <- err
+			}()
+
+			Eventually(errs, 2*time.Second).Should(Receive(MatchError(ContainSubstring("context deadline exceeded"))))
+		})
+	})
+
 	Context("failure cases", func() {
 		Context("when the token cannot be retrieved", func() {
 			It("returns an error", func() {
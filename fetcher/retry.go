@@ -0,0 +1,95 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how failed GET requests are retried. Requests are
+// retried on 429 Too Many Requests, on 5xx statuses other than 501 Not
+// Implemented, and when the connection is reset, waiting a random delay of up
+// to BaseDelay doubled for every attempt and capped at MaxDelay. A Retry-After
+// header takes precedence over the computed delay but is capped at MaxDelay as
+// well. No retry is made when the delay would outlast the context deadline.
+type RetryPolicy struct {
+	// MaxAttempts includes the first attempt, so 1 disables retries.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy is used by an Environment whose Retry is the zero value.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+}
+
+func (p RetryPolicy) orDefault() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		return DefaultRetryPolicy
+	}
+	return p
+}
+
+// delay returns how long to wait before retrying after the given attempt,
+// counted from 1.
+func (p RetryPolicy) delay(attempt int, res *http.Response) time.Duration {
+	if res != nil {
+		if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			if p.MaxDelay > 0 && time.Duration(seconds) > p.MaxDelay/time.Second {
+				return p.MaxDelay
+			}
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	ceiling := p.BaseDelay << uint(attempt-1)
+	if ceiling <= 0 || (p.MaxDelay > 0 && ceiling > p.MaxDelay) {
+		ceiling = p.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+func shouldRetry(res *http.Response, err error) bool {
+	if err != nil {
+		return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+	}
+
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests:
+		return true
+	case res.StatusCode == http.StatusNotImplemented:
+		return false
+	}
+	return res.StatusCode >= 500
+}
+
+// fitsDeadline reports whether waiting d still leaves time before the
+// deadline of ctx, if it has one.
+func fitsDeadline(ctx context.Context, d time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > d
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
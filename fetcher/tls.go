@@ -0,0 +1,82 @@
+package fetcher
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// TLSOptions configures how the Ops Manager and UAA certificates are
+// verified. Certificates are verified against the system pool unless CACert is
+// given. Certificate and key values may be inline PEM or paths to PEM files.
+type TLSOptions struct {
+	// CACert is trusted instead of the system pool, or in addition to it
+	// when UseSystemCertPool is set.
+	CACert            string
+	UseSystemCertPool bool
+
+	// SkipSSLValidation turns off certificate verification altogether.
+	SkipSSLValidation bool
+
+	// ClientCert and ClientKey are presented when the server asks for a
+	// client certificate.
+	ClientCert string
+	ClientKey  string
+}
+
+func (o TLSOptions) Config() (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: o.SkipSSLValidation,
+	}
+
+	if o.CACert != "" {
+		pool := x509.NewCertPool()
+		if o.UseSystemCertPool {
+			system, err := x509.SystemCertPool()
+			if err != nil {
+				return nil, fmt.Errorf("could not load the system certificate pool: %s", err)
+			}
+			pool = system
+		}
+
+		pem, err := readPEM(o.CACert)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("could not parse CA certificate: no PEM certificates found")
+		}
+		config.RootCAs = pool
+	}
+
+	if o.ClientCert != "" || o.ClientKey != "" {
+		certPEM, err := readPEM(o.ClientCert)
+		if err != nil {
+			return nil, err
+		}
+		keyPEM, err := readPEM(o.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %s", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// readPEM returns value itself when it holds PEM data and otherwise reads the
+// file it names.
+func readPEM(value string) ([]byte, error) {
+	if strings.Contains(value, "-----BEGIN") {
+		return []byte(value), nil
+	}
+	return ioutil.ReadFile(value)
+}
@@ -0,0 +1,122 @@
+package fetcher_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/fetcher"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func caCert(server *httptest.Server) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+}
+
+func trust(server *httptest.Server) fetcher.ClientOption {
+	return fetcher.WithTLS(fetcher.TLSOptions{CACert: caCert(server)})
+}
+
+// clientCertificate returns a self-signed client certificate and key as PEM.
+func clientCertificate() (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "om-manifest-validator"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+var _ = Describe("TLS", func() {
+	var server *httptest.Server
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		issueToken(w)
+	})
+
+	BeforeEach(func() {
+		server = httptest.NewTLSServer(handler)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("verifies certificates by default", func() {
+		_, err := fetcher.NewOAuthHTTPClient(server.URL, "username", "password")
+		Expect(err).To(MatchError(ContainSubstring("certificate")))
+	})
+
+	It("trusts a CA certificate read from a file", func() {
+		dir, err := ioutil.TempDir("", "tls")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "ca.pem")
+		Expect(ioutil.WriteFile(path, []byte(caCert(server)), 0644)).To(Succeed())
+
+		_, err = fetcher.NewOAuthHTTPClient(server.URL, "username", "password", fetcher.WithTLS(fetcher.TLSOptions{CACert: path}))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("skips verification when asked to", func() {
+		_, err := fetcher.NewOAuthHTTPClient(server.URL, "username", "password", fetcher.WithTLS(fetcher.TLSOptions{SkipSSLValidation: true}))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("presents client certificates", func() {
+		cert, key := clientCertificate()
+
+		pool := x509.NewCertPool()
+		Expect(pool.AppendCertsFromPEM([]byte(cert))).To(BeTrue())
+
+		mutual := httptest.NewUnstartedServer(handler)
+		mutual.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+		mutual.StartTLS()
+		defer mutual.Close()
+
+		_, err := fetcher.NewOAuthHTTPClient(mutual.URL, "username", "password", trust(mutual))
+		Expect(err).To(HaveOccurred())
+
+		_, err = fetcher.NewOAuthHTTPClient(mutual.URL, "username", "password", fetcher.WithTLS(fetcher.TLSOptions{
+			CACert:     caCert(mutual),
+			ClientCert: cert,
+			ClientKey:  key,
+		}))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("returns an error for invalid CA certificates", func() {
+		_, err := fetcher.TLSOptions{CACert: "-----BEGIN nonsense"}.Config()
+		Expect(err).To(MatchError("could not parse CA certificate: no PEM certificates found"))
+	})
+})
@@ -0,0 +1,138 @@
+package opsfile
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	OpReplace = "replace"
+	OpRemove  = "remove"
+)
+
+// Op is a single operation of a BOSH ops file. Path uses the go-patch syntax
+// understood by bosh.ParsePointer.
+type Op struct {
+	Type  string      `yaml:"type"`
+	Path  string      `yaml:"path"`
+	Value interface{} `yaml:"value,omitempty"`
+	Error string      `yaml:"error,omitempty"`
+}
+
+type Ops []Op
+
+func Load(path string) (Ops, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ops, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return ops, nil
+}
+
+func Parse(b []byte) (Ops, error) {
+	ops := Ops{}
+	if err := yaml.Unmarshal(b, &ops); err != nil {
+		return nil, err
+	}
+
+	for i, op := range ops {
+		if op.Type != OpReplace && op.Type != OpRemove {
+			return nil, fmt.Errorf("op %d: unknown type %q", i+1, op.Type)
+		}
+		if _, err := bosh.ParsePointer(op.Path); err != nil {
+			return nil, fmt.Errorf("op %d: %s", i+1, err)
+		}
+	}
+
+	return ops, nil
+}
+
+// Apply runs every op against doc, a document made of maps, yaml.MapSlices and
+// []interface{}, and returns the patched document. doc itself may be modified.
+func (ops Ops) Apply(doc interface{}) (interface{}, error) {
+	for i, op := range ops {
+		p, err := bosh.ParsePointer(op.Path)
+		if err != nil {
+			return nil, fmt.Errorf("op %d: %s", i+1, err)
+		}
+
+		switch op.Type {
+		case OpReplace:
+			doc, err = replace(doc, p.Tokens, bosh.CopyValue(op.Value))
+		case OpRemove:
+			doc, err = remove(doc, p.Tokens)
+		default:
+			err = fmt.Errorf("unknown type %q", op.Type)
+		}
+
+		if err != nil {
+			if op.Error != "" {
+				return nil, fmt.Errorf("op %d (%s %s): %s", i+1, op.Type, op.Path, op.Error)
+			}
+			return nil, fmt.Errorf("op %d (%s %s): %s", i+1, op.Type, op.Path, err)
+		}
+	}
+
+	return doc, nil
+}
+
+// ApplyYAML patches a YAML document with each ops file in turn. Key order of
+// the original document is preserved.
+func ApplyYAML(b []byte, opsFiles ...Ops) ([]byte, error) {
+	root := yaml.MapSlice{}
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return nil, err
+	}
+
+	doc, err := applyAll(root, opsFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(doc)
+}
+
+// ApplyManifest returns a copy of the manifest with each ops file applied in
+// turn. The ops patch the document the manifest was parsed from, updated with
+// any changes made to it since, so they can target keys the bosh structs do
+// not model. The original manifest is left untouched.
+func ApplyManifest(m *bosh.Manifest, opsFiles ...Ops) (*bosh.Manifest, error) {
+	node, err := m.MarshalYAML()
+	if err != nil {
+		return nil, err
+	}
+
+	// The node shares values with the manifest, which the ops must not modify.
+	doc, err := applyAll(bosh.CopyValue(node), opsFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	patched, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return bosh.ParseManifest(patched)
+}
+
+func applyAll(doc interface{}, opsFiles []Ops) (interface{}, error) {
+	for _, ops := range opsFiles {
+		var err error
+		doc, err = ops.Apply(doc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
@@ -0,0 +1,13 @@
+package opsfile_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestOpsfile(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Opsfile Suite")
+}
@@ -0,0 +1,250 @@
+package opsfile_test
+
+import (
+	"encoding/json"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+	"github.com/pivotal-cf-experimental/om-manifest-validator/opsfile"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"gopkg.in/yaml.v2"
+)
+
+const manifest = `name: cf
+instance_groups:
+- name: router
+  instances: 2
+  jobs:
+  - name: gorouter
+    properties:
+      router:
+        port: 80
+        enable_ssl: false
+- name: diego_cell
+  instances: 3
+  jobs: []
+`
+
+func mustParse(ops string) opsfile.Ops {
+	parsed, err := opsfile.Parse([]byte(ops))
+	Expect(err).NotTo(HaveOccurred())
+	return parsed
+}
+
+var _ = Describe("Ops files", func() {
+	DescribeTable("ApplyYAML",
+		func(ops, expected string) {
+			patched, err := opsfile.ApplyYAML([]byte(manifest), mustParse(ops))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(patched)).To(Equal(expected))
+		},
+		Entry("replaces a value in place", `
+- type: replace
+  path: /instance_groups/name=router/jobs/name=gorouter/properties/router/enable_ssl
+  value: true
+`, `name: cf
+instance_groups:
+- name: router
+  instances: 2
+  jobs:
+  - name: gorouter
+    properties:
+      router:
+        port: 80
+        enable_ssl: true
+- name: diego_cell
+  instances: 3
+  jobs: []
+`),
+		Entry("creates optional keys and appends to lists", `
+- type: replace
+  path: /instance_groups/name=router/jobs/name=gorouter/properties/router/tls?/port
+  value: 443
+- type: replace
+  path: /instance_groups/name=diego_cell/jobs/-
+  value: {name: rep}
+- type: replace
+  path: /instance_groups/name=uaa?/instances
+  value: 1
+`, `name: cf
+instance_groups:
+- name: router
+  instances: 2
+  jobs:
+  - name: gorouter
+    properties:
+      router:
+        port: 80
+        enable_ssl: false
+        tls:
+          port: 443
+- name: diego_cell
+  instances: 3
+  jobs:
+  - name: rep
+- name: uaa
+  instances: 1
+`),
+		Entry("removes keys and elements", `
+- type: remove
+  path: /instance_groups/0/jobs/name=gorouter/properties/router/port
+- type: remove
+  path: /instance_groups/name=diego_cell
+- type: remove
+  path: /instance_groups/name=uaa?
+`, `name: cf
+instance_groups:
+- name: router
+  instances: 2
+  jobs:
+  - name: gorouter
+    properties:
+      router:
+        enable_ssl: false
+`),
+		Entry("replaces the whole document", `
+- type: replace
+  path: /
+  value: {name: other}
+`, "name: other\n"),
+	)
+
+	DescribeTable("failures",
+		func(ops, message string) {
+			_, err := opsfile.ApplyYAML([]byte(manifest), mustParse(ops))
+			Expect(err).To(MatchError(message))
+		},
+		Entry("replacing a missing key", `
+- type: replace
+  path: /instance_groups/name=router/azs
+  value: [z1]
+`, `op 1 (replace /instance_groups/name=router/azs): missing key at token "azs"`),
+		Entry("replacing in a missing element", `
+- type: replace
+  path: /instance_groups/name=uaa/instances
+  value: 1
+`, `op 1 (replace /instance_groups/name=uaa/instances): no element matches at token "name=uaa"`),
+		Entry("removing a missing key", `
+- type: replace
+  path: /name
+  value: cf
+- type: remove
+  path: /update
+`, `op 2 (remove /update): missing key at token "update"`),
+		Entry("indexing past the end", `
+- type: remove
+  path: /instance_groups/2
+`, `op 1 (remove /instance_groups/2): index out of range (list has 2 elements) at token "2"`),
+		Entry("a custom error", `
+- type: remove
+  path: /update
+  error: the manifest has no update block
+`, `op 1 (remove /update): the manifest has no update block`),
+	)
+
+	Describe("Apply", func() {
+		It("patches JSON decoded documents", func() {
+			var doc interface{}
+			Expect(json.Unmarshal([]byte(`{
+				"name": "cf",
+				"instance_groups": [{"name": "router", "jobs": [{"name": "gorouter", "properties": {"router": {"port": 80}}}]}]
+			}`), &doc)).To(Succeed())
+
+			patched, err := mustParse(`
+- type: replace
+  path: /instance_groups/name=router/jobs/name=gorouter/properties/router/port
+  value: 443
+- type: replace
+  path: /instance_groups/name=router/azs?
+  value: [z1]
+- type: remove
+  path: /name
+`).Apply(doc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(patched).To(Equal(map[string]interface{}{
+				"instance_groups": []interface{}{map[string]interface{}{
+					"name": "router",
+					"azs":  []interface{}{"z1"},
+					"jobs": []interface{}{map[string]interface{}{
+						"name":       "gorouter",
+						"properties": map[string]interface{}{"router": map[string]interface{}{"port": 443}},
+					}},
+				}},
+			}))
+		})
+	})
+
+	Describe("Parse", func() {
+		It("rejects unknown op types", func() {
+			_, err := opsfile.Parse([]byte("- type: test\n  path: /name\n"))
+			Expect(err).To(MatchError(`op 1: unknown type "test"`))
+		})
+
+		It("rejects invalid paths", func() {
+			_, err := opsfile.Parse([]byte("- type: remove\n  path: name\n"))
+			Expect(err).To(MatchError(`op 1: path must start with / at token "" of path "name"`))
+		})
+	})
+
+	Describe("ApplyManifest", func() {
+		It("returns a patched copy of the manifest", func() {
+			m, err := bosh.ParseManifest([]byte(manifest))
+			Expect(err).NotTo(HaveOccurred())
+
+			ops := mustParse(`
+- type: replace
+  path: /instance_groups/name=router/instances
+  value: 4
+`)
+			patched, err := opsfile.ApplyManifest(m, ops, ops)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(patched.MustFindInstanceGroupNamed("router").Instances()).To(Equal(4))
+			Expect(m.MustFindInstanceGroupNamed("router").Instances()).To(Equal(2))
+		})
+
+		It("keeps and patches keys the manifest structs do not model", func() {
+			m, err := bosh.ParseManifest([]byte(`update:
+  canaries: 1
+  extra_update_key: x
+releases:
+- name: routing
+  version: "1"
+  extra_release_key: kept
+`))
+			Expect(err).NotTo(HaveOccurred())
+
+			patched, err := opsfile.ApplyManifest(m, mustParse(`
+- type: replace
+  path: /update/extra_update_key
+  value: z
+`))
+			Expect(err).NotTo(HaveOccurred())
+
+			b, err := yaml.Marshal(patched)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(b)).To(Equal("update:\n  canaries: 1\n  extra_update_key: z\nreleases:\n- name: routing\n  version: \"1\"\n  extra_release_key: kept\n"))
+
+			original, err := yaml.Marshal(m)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(original)).To(ContainSubstring("extra_update_key: x"))
+		})
+
+		It("patches changes made to the manifest after parsing it", func() {
+			m, err := bosh.ParseManifest([]byte(manifest))
+			Expect(err).NotTo(HaveOccurred())
+			m.Name = "renamed"
+
+			patched, err := opsfile.ApplyManifest(m, mustParse(`
+- type: replace
+  path: /instance_groups/name=router/instances
+  value: 4
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(patched.Name).To(Equal("renamed"))
+		})
+	})
+})
@@ -0,0 +1,206 @@
+package opsfile
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+
+	"gopkg.in/yaml.v2"
+)
+
+// replace returns node with the value at tokens set to value. Missing map keys
+// and list elements are only created for optional tokens, mirroring go-patch.
+func replace(node interface{}, tokens []bosh.Token, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	last := len(tokens) == 1
+
+	switch token := tokens[0].(type) {
+	case bosh.KeyToken:
+		child, found, ok := bosh.MapValue(node, token.Key)
+		if !ok {
+			return nil, tokenError(token, "expected a map but found %s", bosh.TypeName(node))
+		}
+		if !found {
+			if !token.Optional {
+				return nil, tokenError(token, "missing key")
+			}
+			if !last {
+				child = newContainer(tokens[1])
+			}
+		}
+
+		child, err := replace(child, tokens[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		return setChild(node, token, child)
+	case bosh.IndexToken:
+		list, ok := node.([]interface{})
+		if !ok {
+			return nil, tokenError(token, "expected a list but found %s", bosh.TypeName(node))
+		}
+
+		index, ok := token.Resolve(len(list))
+		if !ok {
+			return nil, tokenError(token, "index out of range (list has %d elements)", len(list))
+		}
+
+		child, err := replace(list[index], tokens[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		list[index] = child
+		return list, nil
+	case bosh.AfterLastIndexToken:
+		list, ok := node.([]interface{})
+		if node != nil && !ok {
+			return nil, tokenError(token, "expected a list but found %s", bosh.TypeName(node))
+		}
+		if !last {
+			return nil, tokenError(token, "must be the last token of the path")
+		}
+		return append(list, value), nil
+	case bosh.MatchingIndexToken:
+		list, ok := node.([]interface{})
+		if node != nil && !ok {
+			return nil, tokenError(token, "expected a list but found %s", bosh.TypeName(node))
+		}
+
+		matches := token.Indices(list)
+		switch len(matches) {
+		case 0:
+			if !token.Optional {
+				return nil, tokenError(token, "no element matches")
+			}
+			var child interface{}
+			if !last {
+				child = yaml.MapSlice{{Key: token.Key, Value: token.Value}}
+			}
+			child, err := replace(child, tokens[1:], value)
+			if err != nil {
+				return nil, err
+			}
+			return append(list, child), nil
+		case 1:
+			child, err := replace(list[matches[0]], tokens[1:], value)
+			if err != nil {
+				return nil, err
+			}
+			list[matches[0]] = child
+			return list, nil
+		default:
+			return nil, tokenError(token, "expected one element to match but found %d", len(matches))
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported token %s", tokens[0])
+}
+
+// remove returns node without the value at tokens. Removing a missing optional
+// value is not an error.
+func remove(node interface{}, tokens []bosh.Token) (interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, errors.New("cannot remove the whole document")
+	}
+
+	last := len(tokens) == 1
+
+	switch token := tokens[0].(type) {
+	case bosh.KeyToken:
+		child, found, ok := bosh.MapValue(node, token.Key)
+		if !ok {
+			return nil, tokenError(token, "expected a map but found %s", bosh.TypeName(node))
+		}
+		if !found {
+			if token.Optional {
+				return node, nil
+			}
+			return nil, tokenError(token, "missing key")
+		}
+
+		if last {
+			return bosh.DeleteMapValue(node, token.Key), nil
+		}
+
+		child, err := remove(child, tokens[1:])
+		if err != nil {
+			return nil, err
+		}
+		return setChild(node, token, child)
+	case bosh.IndexToken:
+		list, ok := node.([]interface{})
+		if !ok {
+			return nil, tokenError(token, "expected a list but found %s", bosh.TypeName(node))
+		}
+
+		index, ok := token.Resolve(len(list))
+		if !ok {
+			return nil, tokenError(token, "index out of range (list has %d elements)", len(list))
+		}
+
+		return removeOrRecurse(list, index, tokens)
+	case bosh.MatchingIndexToken:
+		list, ok := node.([]interface{})
+		if !ok {
+			return nil, tokenError(token, "expected a list but found %s", bosh.TypeName(node))
+		}
+
+		matches := token.Indices(list)
+		switch len(matches) {
+		case 0:
+			if token.Optional {
+				return node, nil
+			}
+			return nil, tokenError(token, "no element matches")
+		case 1:
+			return removeOrRecurse(list, matches[0], tokens)
+		default:
+			return nil, tokenError(token, "expected one element to match but found %d", len(matches))
+		}
+	case bosh.AfterLastIndexToken:
+		return nil, tokenError(token, "cannot remove the element after the last one")
+	}
+
+	return nil, fmt.Errorf("unsupported token %s", tokens[0])
+}
+
+func removeOrRecurse(list []interface{}, index int, tokens []bosh.Token) (interface{}, error) {
+	if len(tokens) == 1 {
+		return append(list[:index], list[index+1:]...), nil
+	}
+
+	child, err := remove(list[index], tokens[1:])
+	if err != nil {
+		return nil, err
+	}
+	list[index] = child
+	return list, nil
+}
+
+// setChild stores child under the key of token, which fails for typed maps
+// that cannot hold it.
+func setChild(node interface{}, token bosh.KeyToken, child interface{}) (interface{}, error) {
+	node, ok := bosh.SetMapValue(node, token.Key, child)
+	if !ok {
+		return nil, tokenError(token, "%s cannot hold %s", bosh.TypeName(node), bosh.TypeName(child))
+	}
+	return node, nil
+}
+
+func tokenError(token bosh.Token, reason string, args ...interface{}) error {
+	return fmt.Errorf("%s at token %q", fmt.Sprintf(reason, args...), token.String())
+}
+
+// newContainer returns an empty value suitable for the token that will be
+// resolved against it.
+func newContainer(next bosh.Token) interface{} {
+	switch next.(type) {
+	case bosh.KeyToken:
+		return yaml.MapSlice{}
+	}
+	return []interface{}{}
+}
@@ -0,0 +1,113 @@
+package release
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+)
+
+// Index holds the releases available to look up job specs for a manifest.
+type Index struct {
+	releases []*Release
+}
+
+func NewIndex(releases ...*Release) *Index {
+	return &Index{releases: releases}
+}
+
+// LoadIndex loads every release tarball or directory in paths.
+func LoadIndex(paths ...string) (*Index, error) {
+	i := NewIndex()
+	for _, path := range paths {
+		r, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		i.Add(r)
+	}
+	return i, nil
+}
+
+func (i *Index) Add(r *Release) {
+	i.releases = append(i.releases, r)
+}
+
+func (i *Index) Releases() []*Release {
+	return i.releases
+}
+
+// Release finds a release by the name and version used in a manifest. A
+// version of "latest", or none at all, picks the highest version, and a
+// release loaded from source, which has no version, matches any version.
+func (i *Index) Release(name, version string) (*Release, error) {
+	var found *Release
+	for _, r := range i.releases {
+		if r.Name != name {
+			continue
+		}
+
+		if version != "" && version != "latest" {
+			if r.Version == version {
+				return r, nil
+			}
+			if r.Version == "" && found == nil {
+				found = r
+			}
+			continue
+		}
+
+		if found == nil || compareVersions(r.Version, found.Version) > 0 {
+			found = r
+		}
+	}
+
+	if found == nil {
+		if version == "" {
+			return nil, fmt.Errorf("%w: '%s'", ErrReleaseNotFound, name)
+		}
+		return nil, fmt.Errorf("%w: '%s/%s'", ErrReleaseNotFound, name, version)
+	}
+	return found, nil
+}
+
+// JobSpec returns the spec of a manifest job, using the release version the
+// manifest names in its releases section.
+func (i *Index) JobSpec(m *bosh.Manifest, job *bosh.Job) (*JobSpec, error) {
+	version := ""
+	for _, r := range m.Releases {
+		if r.Name == job.Release {
+			version = r.Version
+			break
+		}
+	}
+
+	r, err := i.Release(job.Release, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Job(job.Name())
+}
+
+// compareVersions compares dotted versions such as 0.145.0 or 1.2.3-dev
+// segment by segment, numerically where both segments are numbers.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for k := 0; k < len(as) && k < len(bs); k++ {
+		an, aErr := strconv.Atoi(as[k])
+		bn, bErr := strconv.Atoi(bs[k])
+
+		switch {
+		case aErr == nil && bErr == nil && an != bn:
+			if an < bn {
+				return -1
+			}
+			return 1
+		case (aErr != nil || bErr != nil) && as[k] != bs[k]:
+			return strings.Compare(as[k], bs[k])
+		}
+	}
+	return len(as) - len(bs)
+}
@@ -0,0 +1,266 @@
+package release
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	ErrReleaseNotFound = errors.New("release not found")
+	ErrJobNotFound     = errors.New("job not found in release")
+)
+
+// Release is the set of job specs of one release version. Releases read from
+// a source directory have no version.
+type Release struct {
+	Name    string
+	Version string
+	Jobs    []*JobSpec
+}
+
+func (r *Release) Job(name string) (*JobSpec, error) {
+	for _, job := range r.Jobs {
+		if job.Name == name {
+			return job, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: '%s' in release '%s'", ErrJobNotFound, name, r)
+}
+
+func (r *Release) String() string {
+	if r.Version == "" {
+		return r.Name
+	}
+	return r.Name + "/" + r.Version
+}
+
+type releaseManifest struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+}
+
+// Load reads a release tarball or a release directory, depending on what path
+// points at.
+func Load(path string) (*Release, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return LoadDir(path)
+	}
+	return LoadTarball(path)
+}
+
+// LoadTarball reads a release tarball as built by bosh create-release or
+// downloaded from bosh.io, with a release.MF and a jobs/<name>.tgz per job.
+func LoadTarball(tarball string) (*Release, error) {
+	f, err := os.Open(tarball)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := &Release{}
+	err = walkTarball(f, func(name string, contents io.Reader) error {
+		switch {
+		case name == "release.MF":
+			return parseReleaseManifest(contents, r)
+		case path.Dir(name) == "jobs" && strings.HasSuffix(name, ".tgz"):
+			job, err := readJobTarball(contents)
+			if err != nil {
+				return fmt.Errorf("%s: %s", name, err)
+			}
+			r.Jobs = append(r.Jobs, job)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", tarball, err)
+	}
+
+	if r.Name == "" {
+		return nil, fmt.Errorf("%s: no release.MF found", tarball)
+	}
+
+	sortJobs(r.Jobs)
+	return r, nil
+}
+
+// LoadDir reads an extracted release tarball, in which jobs are either still
+// packed as jobs/<name>.tgz or extracted into jobs/<name>/job.MF, or a release
+// source directory with jobs/<name>/spec. The name of a source release comes
+// from config/final.yml.
+func LoadDir(dir string) (*Release, error) {
+	r := &Release{}
+
+	f, err := os.Open(filepath.Join(dir, "release.MF"))
+	switch {
+	case err == nil:
+		err = parseReleaseManifest(f, r)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", dir, err)
+		}
+	case os.IsNotExist(err):
+		if err := readFinalConfig(dir, r); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Join(dir, "jobs"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		job, err := readJobEntry(filepath.Join(dir, "jobs", entry.Name()), entry)
+		if err != nil {
+			return nil, err
+		}
+		if job != nil {
+			r.Jobs = append(r.Jobs, job)
+		}
+	}
+
+	sortJobs(r.Jobs)
+	return r, nil
+}
+
+func readJobEntry(path string, entry os.FileInfo) (*JobSpec, error) {
+	if !entry.IsDir() {
+		if !strings.HasSuffix(entry.Name(), ".tgz") {
+			return nil, nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		job, err := readJobTarball(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		return job, nil
+	}
+
+	for _, name := range []string{"spec", "job.MF"} {
+		job, err := LoadJobSpec(filepath.Join(path, name))
+		if !os.IsNotExist(err) {
+			return job, err
+		}
+	}
+	return nil, nil
+}
+
+func readFinalConfig(dir string, r *Release) error {
+	b, err := ioutil.ReadFile(filepath.Join(dir, "config", "final.yml"))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s: neither release.MF nor config/final.yml found", dir)
+	}
+	if err != nil {
+		return err
+	}
+
+	config := struct {
+		Name string `yaml:"name"`
+	}{}
+	if err := yaml.Unmarshal(b, &config); err != nil {
+		return fmt.Errorf("%s: %s", filepath.Join(dir, "config", "final.yml"), err)
+	}
+
+	r.Name = config.Name
+	return nil
+}
+
+func parseReleaseManifest(contents io.Reader, r *Release) error {
+	b, err := ioutil.ReadAll(contents)
+	if err != nil {
+		return err
+	}
+
+	manifest := releaseManifest{}
+	if err := yaml.Unmarshal(b, &manifest); err != nil {
+		return fmt.Errorf("release.MF: %s", err)
+	}
+
+	r.Name = manifest.Name
+	r.Version = manifest.Version
+	return nil
+}
+
+func readJobTarball(contents io.Reader) (*JobSpec, error) {
+	var job *JobSpec
+	err := walkTarball(contents, func(name string, contents io.Reader) error {
+		if name != "job.MF" {
+			return nil
+		}
+
+		b, err := ioutil.ReadAll(contents)
+		if err != nil {
+			return err
+		}
+		job, err = ParseJobSpec(b)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if job == nil {
+		return nil, errors.New("no job.MF found")
+	}
+	return job, nil
+}
+
+// walkTarball calls visit with every regular file of a gzipped tarball. Names
+// are cleaned, so ./jobs/a.tgz is passed as jobs/a.tgz.
+func walkTarball(r io.Reader, visit func(name string, contents io.Reader) error) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if !header.FileInfo().Mode().IsRegular() {
+			continue
+		}
+
+		if err := visit(path.Clean(strings.TrimPrefix(header.Name, "./")), tr); err != nil {
+			return err
+		}
+	}
+}
+
+func sortJobs(jobs []*JobSpec) {
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Name < jobs[j].Name
+	})
+}
@@ -0,0 +1,270 @@
+package release_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+	"github.com/pivotal-cf-experimental/om-manifest-validator/release"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const gorouterSpec = `---
+name: gorouter
+description: Routes HTTP traffic to applications
+templates:
+  gorouter.yml.erb: config/gorouter.yml
+packages: [gorouter]
+consumes:
+- name: nats
+  type: nats
+  optional: true
+provides:
+- name: gorouter
+  type: http-router
+  properties: [router.port]
+properties:
+  router.port:
+    description: Listening port
+    default: 80
+  router.status.user:
+    description: Username for the status endpoint
+    default: router-status
+  router.status.password:
+    description: Password for the status endpoint
+  router.ssl_skip_validation:
+    default: ~
+  router.tls_pem:
+    example: [{cert_chain: "...", private_key: "..."}]
+`
+
+const releaseMF = `---
+name: routing
+version: 0.145.0
+jobs:
+- name: gorouter
+  version: abc
+`
+
+func gzippedTar(files map[string][]byte) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for name, contents := range files {
+		Expect(tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents)), Typeflag: tar.TypeReg})).To(Succeed())
+		_, err := tw.Write(contents)
+		Expect(err).NotTo(HaveOccurred())
+	}
+	Expect(tw.Close()).To(Succeed())
+	Expect(gz.Close()).To(Succeed())
+	return buf.Bytes()
+}
+
+func writeFile(path string, contents []byte) {
+	Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+	Expect(ioutil.WriteFile(path, contents, 0644)).To(Succeed())
+}
+
+var _ = Describe("Release", func() {
+	var (
+		dir     string
+		jobTgz  []byte
+		routing []byte
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "release")
+		Expect(err).NotTo(HaveOccurred())
+
+		jobTgz = gzippedTar(map[string][]byte{
+			"./job.MF":                     []byte(gorouterSpec),
+			"./templates/gorouter.yml.erb": []byte("---"),
+		})
+		routing = gzippedTar(map[string][]byte{
+			"./release.MF":            []byte(releaseMF),
+			"./jobs/gorouter.tgz":     jobTgz,
+			"./packages/gorouter.tgz": []byte("not read"),
+		})
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("ParseJobSpec", func() {
+		var spec *release.JobSpec
+
+		BeforeEach(func() {
+			var err error
+			spec, err = release.ParseJobSpec([]byte(gorouterSpec))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("exposes properties, links and templates", func() {
+			Expect(spec.Name).To(Equal("gorouter"))
+			Expect(spec.Templates).To(HaveKeyWithValue("gorouter.yml.erb", "config/gorouter.yml"))
+			Expect(spec.Consumes).To(Equal([]release.Link{{Name: "nats", Type: "nats", Optional: true}}))
+			Expect(spec.Provides[0].Properties).To(Equal([]string{"router.port"}))
+
+			Expect(spec.PropertyNames()).To(Equal([]string{
+				"router.port",
+				"router.ssl_skip_validation",
+				"router.status.password",
+				"router.status.user",
+				"router.tls_pem",
+			}))
+			Expect(spec.Properties["router.port"].Description).To(Equal("Listening port"))
+		})
+
+		It("tells null defaults apart from missing ones", func() {
+			Expect(spec.Properties["router.ssl_skip_validation"].HasDefault).To(BeTrue())
+			Expect(spec.Properties["router.status.password"].HasDefault).To(BeFalse())
+		})
+
+		It("nests the defaults", func() {
+			Expect(spec.Defaults()).To(Equal(bosh.Properties{
+				"router": bosh.Properties{
+					"port":                80,
+					"ssl_skip_validation": nil,
+					"status": bosh.Properties{
+						"user": "router-status",
+					},
+				},
+			}))
+		})
+
+		It("knows which properties are declared", func() {
+			Expect(spec.Declares("router.port")).To(BeTrue())
+			Expect(spec.Declares("router.status")).To(BeTrue())
+			Expect(spec.Declares("router.tls_pem.cert_chain")).To(BeTrue())
+			Expect(spec.Declares("router.prot")).To(BeFalse())
+			Expect(spec.Declares("route")).To(BeFalse())
+		})
+
+		It("requires a name", func() {
+			_, err := release.ParseJobSpec([]byte("properties: {}"))
+			Expect(err).To(MatchError("job spec has no name"))
+		})
+	})
+
+	Describe("Load", func() {
+		It("reads release tarballs", func() {
+			path := filepath.Join(dir, "routing-0.145.0.tgz")
+			writeFile(path, routing)
+
+			r, err := release.Load(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.String()).To(Equal("routing/0.145.0"))
+
+			job, err := r.Job("gorouter")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(job.Description).To(Equal("Routes HTTP traffic to applications"))
+		})
+
+		It("reads extracted release tarballs", func() {
+			writeFile(filepath.Join(dir, "release.MF"), []byte(releaseMF))
+			writeFile(filepath.Join(dir, "jobs", "gorouter.tgz"), jobTgz)
+			writeFile(filepath.Join(dir, "jobs", "tcp_router", "job.MF"), []byte("name: tcp_router"))
+
+			r, err := release.Load(dir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Version).To(Equal("0.145.0"))
+			Expect(r.Jobs).To(HaveLen(2))
+			Expect(r.Jobs[1].Name).To(Equal("tcp_router"))
+		})
+
+		It("reads release source directories", func() {
+			writeFile(filepath.Join(dir, "config", "final.yml"), []byte("name: routing\nblobstore: {}"))
+			writeFile(filepath.Join(dir, "jobs", "gorouter", "spec"), []byte(gorouterSpec))
+
+			r, err := release.Load(dir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.String()).To(Equal("routing"))
+			Expect(r.Job("gorouter")).NotTo(BeNil())
+		})
+
+		It("returns an error for directories that are not releases", func() {
+			_, err := release.Load(dir)
+			Expect(err).To(MatchError(ContainSubstring("neither release.MF nor config/final.yml found")))
+		})
+
+		It("returns an error for a job that is not in the release", func() {
+			path := filepath.Join(dir, "routing.tgz")
+			writeFile(path, routing)
+
+			r, err := release.Load(path)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = r.Job("tcp_router")
+			Expect(errors.Is(err, release.ErrJobNotFound)).To(BeTrue())
+		})
+	})
+
+	Describe("Index", func() {
+		var (
+			index    *release.Index
+			manifest *bosh.Manifest
+		)
+
+		BeforeEach(func() {
+			newer, err := release.ParseJobSpec([]byte("name: gorouter\nproperties: {router.port: {default: 8080}}"))
+			Expect(err).NotTo(HaveOccurred())
+			older, err := release.ParseJobSpec([]byte(gorouterSpec))
+			Expect(err).NotTo(HaveOccurred())
+
+			index = release.NewIndex(
+				&release.Release{Name: "routing", Version: "0.145.0", Jobs: []*release.JobSpec{older}},
+				&release.Release{Name: "routing", Version: "0.1000.0", Jobs: []*release.JobSpec{newer}},
+			)
+
+			manifest, err = bosh.ParseManifest([]byte(`---
+releases:
+- name: routing
+  version: 0.145.0
+instance_groups:
+- name: router
+  jobs:
+  - name: gorouter
+    release: routing
+`))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("finds the job spec of the release version in the manifest", func() {
+			job := manifest.MustFindInstanceGroupNamed("router").MustFindJob("gorouter")
+
+			spec, err := index.JobSpec(manifest, job)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spec.Properties["router.port"].Default).To(Equal(80))
+		})
+
+		It("picks the highest version for latest", func() {
+			r, err := index.Release("routing", "latest")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Version).To(Equal("0.1000.0"))
+		})
+
+		It("returns an error for unknown releases", func() {
+			_, err := index.Release("routing", "1.0.0")
+			Expect(errors.Is(err, release.ErrReleaseNotFound)).To(BeTrue())
+			Expect(err).To(MatchError("release not found: 'routing/1.0.0'"))
+		})
+
+		It("loads releases from disk", func() {
+			path := filepath.Join(dir, "routing.tgz")
+			writeFile(path, routing)
+
+			index, err := release.LoadIndex(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(index.Releases()).To(HaveLen(1))
+		})
+	})
+})
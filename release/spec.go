@@ -0,0 +1,156 @@
+package release
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+
+	"gopkg.in/yaml.v2"
+)
+
+// JobSpec is the spec file of a release job, found as jobs/<name>/spec in a
+// release source directory and as job.MF in a built job tarball.
+type JobSpec struct {
+	Name        string                  `yaml:"name"`
+	Description string                  `yaml:"description,omitempty"`
+	Templates   map[string]string       `yaml:"templates,omitempty"`
+	Packages    []string                `yaml:"packages,omitempty"`
+	Consumes    []Link                  `yaml:"consumes,omitempty"`
+	Provides    []Link                  `yaml:"provides,omitempty"`
+	Properties  map[string]PropertySpec `yaml:"properties,omitempty"`
+}
+
+// A JobSpec is what bosh.Manifest.EffectiveProperties resolves against.
+var _ bosh.Spec = &JobSpec{}
+
+// Link is a link a job consumes or provides. Properties lists the job
+// properties shared through a provided link.
+type Link struct {
+	Name       string   `yaml:"name"`
+	Type       string   `yaml:"type"`
+	Optional   bool     `yaml:"optional,omitempty"`
+	Properties []string `yaml:"properties,omitempty"`
+}
+
+// PropertySpec declares a single job property. HasDefault tells a default of
+// null apart from no default at all.
+type PropertySpec struct {
+	Description string      `yaml:"description,omitempty"`
+	Default     interface{} `yaml:"default,omitempty"`
+	Example     interface{} `yaml:"example,omitempty"`
+	HasDefault  bool        `yaml:"-"`
+}
+
+type propertySpecFields PropertySpec
+
+func (p *PropertySpec) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	fields := propertySpecFields{}
+	if err := unmarshal(&fields); err != nil {
+		return err
+	}
+
+	keys := map[string]interface{}{}
+	if err := unmarshal(&keys); err != nil {
+		return err
+	}
+	_, fields.HasDefault = keys["default"]
+
+	*p = PropertySpec(fields)
+	return nil
+}
+
+func LoadJobSpec(path string) (*JobSpec, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := ParseJobSpec(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return spec, nil
+}
+
+func ParseJobSpec(b []byte) (*JobSpec, error) {
+	spec := &JobSpec{}
+	if err := yaml.Unmarshal(b, spec); err != nil {
+		return nil, err
+	}
+
+	if spec.Name == "" {
+		return nil, fmt.Errorf("job spec has no name")
+	}
+
+	return spec, nil
+}
+
+// PropertyNames returns the declared property names in sorted order.
+func (j *JobSpec) PropertyNames() []string {
+	names := make([]string, 0, len(j.Properties))
+	for name := range j.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Declares reports whether a property is covered by the spec. Besides exact
+// matches, this includes values nested inside a declared hash property and
+// maps that only hold declared properties.
+func (j *JobSpec) Declares(name string) bool {
+	if _, found := j.Properties[name]; found {
+		return true
+	}
+
+	for declared := range j.Properties {
+		if strings.HasPrefix(name, declared+".") || strings.HasPrefix(declared, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Defaults returns the default of every property that has one, nested the way
+// BOSH renders them, so that "router.port" becomes {router: {port: ...}}.
+func (j *JobSpec) Defaults() bosh.Properties {
+	defaults := bosh.Properties{}
+
+	for _, name := range j.PropertyNames() {
+		property := j.Properties[name]
+		if !property.HasDefault {
+			continue
+		}
+
+		setDefault(defaults, strings.Split(name, "."), property.Default)
+	}
+
+	return defaults
+}
+
+// setDefault stores value under the nested keys unless one of them is already
+// taken by a value that is not a map.
+func setDefault(p bosh.Properties, keys []string, value interface{}) {
+	existing, taken := p[keys[0]]
+
+	if len(keys) == 1 {
+		if !taken {
+			p[keys[0]] = value
+		}
+		return
+	}
+
+	next, ok := existing.(bosh.Properties)
+	if !ok {
+		if taken {
+			return
+		}
+		next = bosh.Properties{}
+		p[keys[0]] = next
+	}
+	setDefault(next, keys[1:], value)
+}
@@ -0,0 +1,287 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Operators understood by a PropertyAssertion.
+const (
+	OperatorEquals    = "equals"
+	OperatorNotEquals = "not_equals"
+	OperatorMatches   = "matches"
+	OperatorOneOf     = "one_of"
+	OperatorPresent   = "present"
+	OperatorAbsent    = "absent"
+)
+
+// PropertyAssertion is the declarative form of a rule about a single property.
+// When InstanceGroup is empty the assertion applies to every instance group
+// running Job, and when Job is empty Property is looked up in the instance
+// group level properties instead.
+type PropertyAssertion struct {
+	ID            string      `yaml:"id"`
+	Description   string      `yaml:"description"`
+	InstanceGroup string      `yaml:"instance_group"`
+	Job           string      `yaml:"job"`
+	Property      string      `yaml:"property"`
+	Operator      string      `yaml:"operator"`
+	Value         interface{} `yaml:"value"`
+	Severity      string      `yaml:"severity"`
+	Message       string      `yaml:"message"`
+}
+
+type RulesFile struct {
+	Rules []PropertyAssertion `yaml:"rules"`
+}
+
+// LoadRules reads a YAML or JSON rules file and compiles its assertions.
+func LoadRules(path string) ([]Rule, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := ParseRules(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return rules, nil
+}
+
+func ParseRules(b []byte) ([]Rule, error) {
+	f := RulesFile{}
+	if err := yaml.Unmarshal(b, &f); err != nil {
+		return nil, err
+	}
+
+	rules := make([]Rule, 0, len(f.Rules))
+	for i, a := range f.Rules {
+		r, err := NewPropertyRule(a)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %s", i+1, err)
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, nil
+}
+
+type propertyRule struct {
+	assertion PropertyAssertion
+	severity  Severity
+	pattern   *regexp.Regexp
+}
+
+func NewPropertyRule(a PropertyAssertion) (Rule, error) {
+	if a.ID == "" {
+		return nil, errors.New("id is required")
+	}
+	if a.Property == "" {
+		return nil, fmt.Errorf("%s: property is required", a.ID)
+	}
+	if err := bosh.ValidateLens(a.Property); err != nil {
+		return nil, fmt.Errorf("%s: %s", a.ID, err)
+	}
+	if a.InstanceGroup == "" && a.Job == "" {
+		return nil, fmt.Errorf("%s: at least one of instance_group and job is required", a.ID)
+	}
+
+	r := &propertyRule{
+		assertion: a,
+		severity:  Error,
+	}
+
+	if a.Severity != "" {
+		severity, err := ParseSeverity(a.Severity)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", a.ID, err)
+		}
+		r.severity = severity
+	}
+
+	switch a.Operator {
+	case OperatorEquals, OperatorNotEquals, OperatorPresent, OperatorAbsent:
+	case OperatorMatches:
+		pattern, ok := a.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: the value of a %s assertion must be a regular expression", a.ID, a.Operator)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", a.ID, err)
+		}
+		r.pattern = re
+	case OperatorOneOf:
+		if _, ok := a.Value.([]interface{}); !ok {
+			return nil, fmt.Errorf("%s: the value of a %s assertion must be a list", a.ID, a.Operator)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unknown operator %q", a.ID, a.Operator)
+	}
+
+	return r, nil
+}
+
+func (r *propertyRule) ID() string {
+	return r.assertion.ID
+}
+
+func (r *propertyRule) Description() string {
+	return r.assertion.Description
+}
+
+func (r *propertyRule) Severity() Severity {
+	return r.severity
+}
+
+func (r *propertyRule) Check(m *bosh.Manifest) []Finding {
+	a := r.assertion
+
+	var instanceGroups []*bosh.InstanceGroup
+	if a.InstanceGroup != "" {
+		ig := m.InstanceGroupNamed(a.InstanceGroup)
+		if ig == nil {
+			if a.Operator == OperatorAbsent {
+				return nil
+			}
+			return []Finding{{
+				Message:  fmt.Sprintf("instance group %s not found", a.InstanceGroup),
+				Location: Location{InstanceGroup: a.InstanceGroup},
+			}}
+		}
+		instanceGroups = []*bosh.InstanceGroup{ig}
+	} else {
+		for _, ig := range m.InstanceGroups {
+			if ig.FindJob(a.Job) != nil {
+				instanceGroups = append(instanceGroups, ig)
+			}
+		}
+
+		// A misspelt job would otherwise disable the assertion silently.
+		if len(instanceGroups) == 0 && a.Operator != OperatorAbsent {
+			return []Finding{{
+				Message:  fmt.Sprintf("job %s not found", a.Job),
+				Location: Location{Job: a.Job},
+			}}
+		}
+	}
+
+	var findings []Finding
+	for _, ig := range instanceGroups {
+		location := Location{InstanceGroup: ig.Name(), Job: a.Job, Property: a.Property}
+
+		properties := ig.Properties()
+		if a.Job != "" {
+			job := ig.FindJob(a.Job)
+			if job == nil {
+				if a.Operator != OperatorAbsent {
+					findings = append(findings, Finding{
+						Message:  fmt.Sprintf("job %s not found", a.Job),
+						Location: Location{InstanceGroup: ig.Name(), Job: a.Job},
+					})
+				}
+				continue
+			}
+			location.Job = job.Name()
+			properties = job.Properties()
+		}
+
+		// Only a missing value means "not set": a value of the wrong type on
+		// the way cannot be checked either way.
+		value, err := properties.Lookup(a.Property)
+		if err != nil && !errors.Is(err, bosh.ErrPropertyNotFound) {
+			findings = append(findings, Finding{
+				Message:  fmt.Sprintf("cannot check %s: %s", a.Property, err),
+				Location: location,
+			})
+			continue
+		}
+
+		if message := r.evaluate(value, err == nil); message != "" {
+			if a.Message != "" {
+				message = a.Message
+			}
+			findings = append(findings, Finding{Message: message, Location: location})
+		}
+	}
+
+	return findings
+}
+
+// evaluate returns a description of why the assertion does not hold, or an
+// empty string when it does.
+func (r *propertyRule) evaluate(value interface{}, found bool) string {
+	a := r.assertion
+
+	switch a.Operator {
+	case OperatorPresent:
+		if !found {
+			return fmt.Sprintf("%s is not set", a.Property)
+		}
+		return ""
+	case OperatorAbsent:
+		if found {
+			return fmt.Sprintf("%s is set to %v", a.Property, value)
+		}
+		return ""
+	}
+
+	if !found {
+		return fmt.Sprintf("%s is not set", a.Property)
+	}
+
+	switch a.Operator {
+	case OperatorEquals:
+		if !reflect.DeepEqual(normalize(value), normalize(a.Value)) {
+			return fmt.Sprintf("%s is %v, expected %v", a.Property, value, a.Value)
+		}
+	case OperatorNotEquals:
+		if reflect.DeepEqual(normalize(value), normalize(a.Value)) {
+			return fmt.Sprintf("%s must not be %v", a.Property, a.Value)
+		}
+	case OperatorMatches:
+		if !r.pattern.MatchString(fmt.Sprint(value)) {
+			return fmt.Sprintf("%s is %v, expected it to match %s", a.Property, value, r.pattern)
+		}
+	case OperatorOneOf:
+		for _, candidate := range a.Value.([]interface{}) {
+			if reflect.DeepEqual(normalize(value), normalize(candidate)) {
+				return ""
+			}
+		}
+		return fmt.Sprintf("%s is %v, expected one of %v", a.Property, value, a.Value)
+	}
+
+	return ""
+}
+
+// normalize converts bosh.Properties into plain maps so values decoded from a
+// manifest compare equal to the same values decoded from a rules file.
+func normalize(v interface{}) interface{} {
+	switch value := v.(type) {
+	case bosh.Properties:
+		return normalize(map[interface{}]interface{}(value))
+	case map[interface{}]interface{}:
+		out := make(map[interface{}]interface{}, len(value))
+		for k, e := range value {
+			out[k] = normalize(e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(value))
+		for i, e := range value {
+			out[i] = normalize(e)
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,223 @@
+package validator_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+	"github.com/pivotal-cf-experimental/om-manifest-validator/validator"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Property rules", func() {
+	var manifest *bosh.Manifest
+
+	BeforeEach(func() {
+		var err error
+		manifest, err = bosh.ParseManifest([]byte(`---
+instance_groups:
+- name: router
+  instances: 2
+  properties:
+    az_balanced: true
+  jobs:
+  - name: gorouter
+    properties:
+      router:
+        enable_ssl: false
+        port: 80
+        cipher_suites: TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+        status:
+          user: admin
+- name: isolated_router
+  instances: 1
+  jobs:
+  - name: gorouter
+    properties:
+      router:
+        enable_ssl: true
+        port: 443
+`))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	check := func(rulesFile string) []validator.Finding {
+		rules, err := validator.ParseRules([]byte(rulesFile))
+		Expect(err).NotTo(HaveOccurred())
+		return validator.Run(manifest, rules...).Findings
+	}
+
+	It("checks equality", func() {
+		findings := check(`
+rules:
+- id: router-tls
+  instance_group: router
+  job: gorouter
+  property: router.enable_ssl
+  operator: equals
+  value: true
+`)
+		Expect(findings).To(Equal([]validator.Finding{{
+			RuleID:   "router-tls",
+			Severity: validator.Error,
+			Message:  "router.enable_ssl is false, expected true",
+			Location: validator.Location{InstanceGroup: "router", Job: "gorouter", Property: "router.enable_ssl"},
+		}}))
+	})
+
+	It("checks every instance group running the job when no instance group is given", func() {
+		findings := check(`
+rules:
+- id: router-port
+  job: gorouter
+  property: router.port
+  operator: one_of
+  value: [443, 8443]
+  severity: warning
+  message: the router should listen on a TLS port
+`)
+		Expect(findings).To(Equal([]validator.Finding{{
+			RuleID:   "router-port",
+			Severity: validator.Warning,
+			Message:  "the router should listen on a TLS port",
+			Location: validator.Location{InstanceGroup: "router", Job: "gorouter", Property: "router.port"},
+		}}))
+	})
+
+	It("checks nested values against maps", func() {
+		Expect(check(`
+rules:
+- id: status
+  instance_group: router
+  job: gorouter
+  property: router.status
+  operator: not_equals
+  value: {user: admin}
+`)).To(HaveLen(1))
+	})
+
+	It("checks regular expressions", func() {
+		Expect(check(`
+rules:
+- id: ciphers
+  instance_group: router
+  job: gorouter
+  property: router.cipher_suites
+  operator: matches
+  value: ^TLS_ECDHE_
+`)).To(BeEmpty())
+	})
+
+	It("checks presence and absence", func() {
+		findings := check(`
+rules:
+- id: present
+  instance_group: isolated_router
+  job: gorouter
+  property: router.status.user
+  operator: present
+- id: absent
+  instance_group: router
+  property: az_balanced
+  operator: absent
+- id: absent-job
+  instance_group: router
+  job: haproxy
+  property: ha_proxy.port
+  operator: absent
+`)
+		Expect(findings).To(HaveLen(2))
+		Expect(findings[0].Message).To(Equal("router.status.user is not set"))
+		Expect(findings[1].Message).To(Equal("az_balanced is set to true"))
+		Expect(findings[1].Location).To(Equal(validator.Location{InstanceGroup: "router", Property: "az_balanced"}))
+	})
+
+	It("reports missing instance groups and jobs", func() {
+		findings := check(`
+rules:
+- id: missing
+  instance_group: diego_cell
+  job: rep
+  property: diego.rep.port
+  operator: present
+- id: missing-job
+  instance_group: router
+  job: haproxy
+  property: ha_proxy.port
+  operator: present
+`)
+		Expect(findings[0].Message).To(Equal("instance group diego_cell not found"))
+		Expect(findings[1].Message).To(Equal("job haproxy not found"))
+	})
+
+	It("reports jobs that run in no instance group", func() {
+		findings := check(`
+rules:
+- id: typo
+  job: typo_job
+  property: foo
+  operator: present
+- id: typo-absent
+  job: typo_job
+  property: foo
+  operator: absent
+`)
+		Expect(findings).To(HaveLen(1))
+		Expect(findings[0].String()).To(Equal("[error] typo: job typo_job not found (typo_job)"))
+	})
+
+	It("reports values that cannot be checked", func() {
+		findings := check(`
+rules:
+- id: port-list
+  instance_group: router
+  job: gorouter
+  property: router.port[0]
+  operator: absent
+`)
+		Expect(findings).To(HaveLen(1))
+		Expect(findings[0].String()).To(Equal(`[error] port-list: cannot check router.port[0]: value not a list at segment "[0]" of lens "router.port[0]" (router/gorouter:router.port[0])`))
+	})
+
+	It("accepts JSON", func() {
+		Expect(check(`{"rules": [{"id": "tls", "instance_group": "isolated_router", "job": "gorouter", "property": "router.enable_ssl", "operator": "equals", "value": true}]}`)).To(BeEmpty())
+	})
+
+	Describe("LoadRules", func() {
+		It("reads the rules from a file", func() {
+			dir, err := ioutil.TempDir("", "rules")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "rules.yml")
+			Expect(ioutil.WriteFile(path, []byte("rules:\n- {id: a, job: gorouter, property: router.port, operator: present}\n"), 0644)).To(Succeed())
+
+			rules, err := validator.LoadRules(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rules).To(HaveLen(1))
+			Expect(rules[0].ID()).To(Equal("a"))
+		})
+	})
+
+	Describe("invalid assertions", func() {
+		DescribeTable("are rejected when compiled",
+			func(rule, message string) {
+				_, err := validator.ParseRules([]byte("rules:\n- " + rule))
+				Expect(err).To(MatchError(message))
+			},
+			Entry("without an ID", "{job: j, property: p, operator: present}", "rule 1: id is required"),
+			Entry("without a property", "{id: a, job: j, operator: present}", "rule 1: a: property is required"),
+			Entry("without a job or instance group", "{id: a, property: p, operator: present}", "rule 1: a: at least one of instance_group and job is required"),
+			Entry("with a malformed property", "{id: a, job: j, property: router..port, operator: absent}", `rule 1: a: empty segment at segment "router." of lens "router..port"`),
+			Entry("with an unterminated bracket", "{id: a, job: j, property: 'router.port[0', operator: present}", `rule 1: a: unterminated bracket at segment "[0" of lens "router.port[0"`),
+			Entry("with an unknown operator", "{id: a, job: j, property: p, operator: gt}", `rule 1: a: unknown operator "gt"`),
+			Entry("with an unknown severity", "{id: a, job: j, property: p, operator: present, severity: fatal}", `rule 1: a: unknown severity "fatal"`),
+			Entry("with a bad pattern", "{id: a, job: j, property: p, operator: matches, value: '('}", "rule 1: a: error parsing regexp: missing closing ): `(`"),
+			Entry("with a one_of that is not a list", "{id: a, job: j, property: p, operator: one_of, value: 1}", "rule 1: a: the value of a one_of assertion must be a list"),
+		)
+	})
+})
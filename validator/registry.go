@@ -0,0 +1,86 @@
+package validator
+
+import (
+	"fmt"
+	"sync"
+)
+
+type Registry struct {
+	mu    sync.RWMutex
+	rules map[string]Rule
+	order []string
+}
+
+// DefaultRegistry is the registry used by the package level Register and
+// Rules functions, for checks that register themselves from an init func.
+var DefaultRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{
+		rules: map[string]Rule{},
+	}
+}
+
+func (r *Registry) Register(rule Rule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.rules[rule.ID()]; exists {
+		return fmt.Errorf("a rule with ID %q is already registered", rule.ID())
+	}
+
+	r.rules[rule.ID()] = rule
+	r.order = append(r.order, rule.ID())
+	return nil
+}
+
+func (r *Registry) MustRegister(rule Rule) {
+	if err := r.Register(rule); err != nil {
+		panic(err)
+	}
+}
+
+func (r *Registry) Lookup(id string) (Rule, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rule, found := r.rules[id]
+	return rule, found
+}
+
+// Rules returns every registered rule in registration order.
+func (r *Registry) Rules() []Rule {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rules := make([]Rule, 0, len(r.order))
+	for _, id := range r.order {
+		rules = append(rules, r.rules[id])
+	}
+	return rules
+}
+
+// Select returns the rules with the given IDs, failing if any is unknown.
+func (r *Registry) Select(ids ...string) ([]Rule, error) {
+	rules := make([]Rule, 0, len(ids))
+	for _, id := range ids {
+		rule, found := r.Lookup(id)
+		if !found {
+			return nil, fmt.Errorf("no rule with ID %q is registered", id)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func Register(rule Rule) error {
+	return DefaultRegistry.Register(rule)
+}
+
+func MustRegister(rule Rule) {
+	DefaultRegistry.MustRegister(rule)
+}
+
+func Rules() []Rule {
+	return DefaultRegistry.Rules()
+}
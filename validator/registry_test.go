@@ -0,0 +1,58 @@
+package validator_test
+
+import (
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+	"github.com/pivotal-cf-experimental/om-manifest-validator/validator"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+	var (
+		registry *validator.Registry
+		first    validator.Rule
+		second   validator.Rule
+	)
+
+	BeforeEach(func() {
+		noop := func(*bosh.Manifest) []validator.Finding { return nil }
+		first = validator.NewRule("first", "", validator.Error, noop)
+		second = validator.NewRule("second", "", validator.Error, noop)
+
+		registry = validator.NewRegistry()
+		Expect(registry.Register(first)).To(Succeed())
+		Expect(registry.Register(second)).To(Succeed())
+	})
+
+	It("returns the rules in registration order", func() {
+		Expect(registry.Rules()).To(Equal([]validator.Rule{first, second}))
+	})
+
+	It("looks rules up by ID", func() {
+		rule, found := registry.Lookup("second")
+		Expect(found).To(BeTrue())
+		Expect(rule).To(Equal(second))
+
+		_, found = registry.Lookup("third")
+		Expect(found).To(BeFalse())
+	})
+
+	It("rejects duplicate IDs", func() {
+		Expect(registry.Register(first)).To(MatchError(`a rule with ID "first" is already registered`))
+		Expect(func() { registry.MustRegister(first) }).To(Panic())
+	})
+
+	Describe("Select", func() {
+		It("returns the requested rules", func() {
+			rules, err := registry.Select("second", "first")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rules).To(Equal([]validator.Rule{second, first}))
+		})
+
+		It("fails on unknown IDs", func() {
+			_, err := registry.Select("first", "third")
+			Expect(err).To(MatchError(`no rule with ID "third" is registered`))
+		})
+	})
+})
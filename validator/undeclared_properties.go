@@ -0,0 +1,85 @@
+package validator
+
+import (
+	"fmt"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+	"github.com/pivotal-cf-experimental/om-manifest-validator/release"
+)
+
+const UndeclaredPropertiesRuleID = "undeclared-properties"
+
+// NewUndeclaredPropertiesRule returns a rule that reports job properties the
+// spec of the job does not declare. BOSH ignores such properties, so they are
+// usually typos. Jobs whose release is not in index are reported as info.
+func NewUndeclaredPropertiesRule(index *release.Index) Rule {
+	return NewRule(
+		UndeclaredPropertiesRuleID,
+		"job properties must be declared in the release job spec",
+		Warning,
+		func(m *bosh.Manifest) []Finding {
+			return checkUndeclaredProperties(m, index)
+		},
+	)
+}
+
+func checkUndeclaredProperties(m *bosh.Manifest, index *release.Index) []Finding {
+	var findings []Finding
+
+	for _, j := range manifestJobs(m) {
+		job, location := j.job, j.location
+
+		spec, err := index.JobSpec(m, job)
+		if err != nil {
+			findings = append(findings, Finding{
+				Severity: Info,
+				Message:  fmt.Sprintf("cannot check properties: %s", err),
+				Location: location,
+			})
+			continue
+		}
+
+		declared := spec.PropertyNames()
+		for _, lens := range job.Properties().Leaves() {
+			if spec.Declares(lens) {
+				continue
+			}
+
+			message := fmt.Sprintf("%s is not declared in the spec of job %s", lens, spec.Name)
+			if suggestion := suggest(lens, declared); suggestion != "" {
+				message += fmt.Sprintf(", did you mean %s?", suggestion)
+			}
+
+			location.Property = lens
+			findings = append(findings, Finding{Message: message, Location: location})
+		}
+	}
+
+	return findings
+}
+
+type locatedJob struct {
+	job      *bosh.Job
+	location Location
+}
+
+// manifestJobs returns the jobs of every instance group, then those of every
+// addon. The jobs of a v1 manifest are deployment jobs that list their release
+// jobs under templates, so they have no spec of their own to check.
+func manifestJobs(m *bosh.Manifest) []locatedJob {
+	var jobs []locatedJob
+
+	for _, ig := range m.InstanceGroups {
+		for _, job := range ig.Jobs() {
+			jobs = append(jobs, locatedJob{job, Location{InstanceGroup: ig.Name(), Job: job.Name()}})
+		}
+	}
+
+	for _, addon := range m.Addons {
+		for _, job := range addon.Jobs {
+			jobs = append(jobs, locatedJob{job, Location{Addon: addon.Name, Job: job.Name()}})
+		}
+	}
+
+	return jobs
+}
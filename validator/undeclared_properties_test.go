@@ -0,0 +1,107 @@
+package validator_test
+
+import (
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+	"github.com/pivotal-cf-experimental/om-manifest-validator/release"
+	"github.com/pivotal-cf-experimental/om-manifest-validator/validator"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Undeclared properties rule", func() {
+	var (
+		index    *release.Index
+		manifest *bosh.Manifest
+	)
+
+	BeforeEach(func() {
+		spec, err := release.ParseJobSpec([]byte(`---
+name: gorouter
+properties:
+  router.enable_ssl: {default: false}
+  router.port: {default: 80}
+  router.tls_pem: {description: a list of certificates}
+  uaa.clients.gorouter.secret: {}
+`))
+		Expect(err).NotTo(HaveOccurred())
+		index = release.NewIndex(&release.Release{Name: "routing", Version: "1.0.0", Jobs: []*release.JobSpec{spec}})
+
+		manifest, err = bosh.ParseManifest([]byte(`---
+releases:
+- name: routing
+  version: 1.0.0
+instance_groups:
+- name: router
+  jobs:
+  - name: gorouter
+    release: routing
+    properties:
+      router:
+        enable_ssls: true
+        port: 443
+        tls_pem:
+        - cert_chain: cert
+        status_password: secret
+      uaa:
+        clients:
+          gorouter: {}
+  - name: metron_agent
+    release: loggregator
+`))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("reports undeclared properties with a suggestion", func() {
+		report := validator.Run(manifest, validator.NewUndeclaredPropertiesRule(index))
+
+		Expect(report.Findings).To(ContainElement(validator.Finding{
+			RuleID:   validator.UndeclaredPropertiesRuleID,
+			Severity: validator.Warning,
+			Message:  "router.enable_ssls is not declared in the spec of job gorouter, did you mean router.enable_ssl?",
+			Location: validator.Location{InstanceGroup: "router", Job: "gorouter", Property: "router.enable_ssls"},
+		}))
+		Expect(report.Findings).To(ContainElement(validator.Finding{
+			RuleID:   validator.UndeclaredPropertiesRuleID,
+			Severity: validator.Warning,
+			Message:  "router.status_password is not declared in the spec of job gorouter",
+			Location: validator.Location{InstanceGroup: "router", Job: "gorouter", Property: "router.status_password"},
+		}))
+	})
+
+	It("reports jobs whose release is not available as info", func() {
+		report := validator.Run(manifest, validator.NewUndeclaredPropertiesRule(index))
+
+		Expect(report.Findings).To(HaveLen(3))
+		Expect(report.Count(validator.Info)).To(Equal(1))
+		Expect(report.Findings[2].Message).To(Equal("cannot check properties: release not found: 'loggregator'"))
+	})
+
+	It("checks the jobs of addons but not the deployment jobs of v1 manifests", func() {
+		manifest, err := bosh.ParseManifest([]byte(`---
+releases:
+- name: routing
+  version: 1.0.0
+addons:
+- name: tls
+  jobs:
+  - name: gorouter
+    release: routing
+    properties:
+      router: {enable_ssls: true}
+jobs:
+- name: router
+  templates:
+  - name: gorouter
+    release: routing
+  properties:
+    router: {prot: 80}
+`))
+		Expect(err).NotTo(HaveOccurred())
+
+		report := validator.Run(manifest, validator.NewUndeclaredPropertiesRule(index))
+
+		Expect(report.Findings).To(HaveLen(1))
+		Expect(report.Findings[0].String()).To(Equal("[warning] undeclared-properties: router.enable_ssls is not declared in the spec of job gorouter, did you mean router.enable_ssl? (addon tls/gorouter:router.enable_ssls)"))
+	})
+})
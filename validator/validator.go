@@ -0,0 +1,176 @@
+package validator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+)
+
+type Severity int
+
+// The zero Severity is deliberately not a valid level: findings that leave it
+// unset are reported at the severity of the rule that produced them.
+const (
+	Info Severity = iota + 1
+	Warning
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+func ParseSeverity(s string) (Severity, error) {
+	switch strings.ToLower(s) {
+	case "info":
+		return Info, nil
+	case "warning", "warn":
+		return Warning, nil
+	case "error":
+		return Error, nil
+	}
+	return 0, fmt.Errorf("unknown severity %q", s)
+}
+
+// Location points at the part of the manifest a finding is about. Any of the
+// fields may be empty when the finding applies to the whole manifest or a
+// whole instance group. Addon is set instead of InstanceGroup for the jobs of
+// an addon.
+type Location struct {
+	InstanceGroup string
+	Addon         string
+	Job           string
+	Property      string
+}
+
+func (l Location) String() string {
+	s := l.InstanceGroup
+	if l.Addon != "" {
+		s = "addon " + l.Addon
+	}
+	if l.Job != "" {
+		if s != "" {
+			s += "/"
+		}
+		s += l.Job
+	}
+	if l.Property != "" {
+		if s != "" {
+			s += ":"
+		}
+		s += l.Property
+	}
+	return s
+}
+
+type Finding struct {
+	RuleID   string
+	Severity Severity
+	Message  string
+	Location Location
+}
+
+func (f Finding) String() string {
+	if loc := f.Location.String(); loc != "" {
+		return fmt.Sprintf("[%s] %s: %s (%s)", f.Severity, f.RuleID, f.Message, loc)
+	}
+	return fmt.Sprintf("[%s] %s: %s", f.Severity, f.RuleID, f.Message)
+}
+
+type Rule interface {
+	ID() string
+	Description() string
+	Severity() Severity
+	Check(*bosh.Manifest) []Finding
+}
+
+type rule struct {
+	id          string
+	description string
+	severity    Severity
+	check       func(*bosh.Manifest) []Finding
+}
+
+// NewRule builds a Rule from a check function.
+func NewRule(id, description string, severity Severity, check func(*bosh.Manifest) []Finding) Rule {
+	return &rule{
+		id:          id,
+		description: description,
+		severity:    severity,
+		check:       check,
+	}
+}
+
+func (r *rule) ID() string {
+	return r.id
+}
+
+func (r *rule) Description() string {
+	return r.description
+}
+
+func (r *rule) Severity() Severity {
+	return r.severity
+}
+
+func (r *rule) Check(m *bosh.Manifest) []Finding {
+	return r.check(m)
+}
+
+type Report struct {
+	Findings []Finding
+}
+
+func (r Report) HasErrors() bool {
+	return r.Count(Error) > 0
+}
+
+func (r Report) Count(severity Severity) int {
+	count := 0
+	for _, f := range r.Findings {
+		if f.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
+
+// Run checks the manifest against each rule in turn. Findings are stamped with
+// the ID of the rule that produced them, and a rule that panics, e.g. through
+// one of the bosh.Must* lookups, is reported as an error finding rather than
+// aborting the run.
+func Run(m *bosh.Manifest, rules ...Rule) Report {
+	report := Report{}
+	for _, r := range rules {
+		for _, f := range check(r, m) {
+			f.RuleID = r.ID()
+			if f.Severity == 0 {
+				f.Severity = r.Severity()
+			}
+			report.Findings = append(report.Findings, f)
+		}
+	}
+	return report
+}
+
+func check(r Rule, m *bosh.Manifest) (findings []Finding) {
+	defer func() {
+		if err := recover(); err != nil {
+			findings = []Finding{{
+				Severity: Error,
+				Message:  fmt.Sprintf("rule failed to run: %v", err),
+			}}
+		}
+	}()
+
+	return r.Check(m)
+}
@@ -0,0 +1,91 @@
+package validator_test
+
+import (
+	"github.com/pivotal-cf-experimental/om-manifest-validator/bosh"
+	"github.com/pivotal-cf-experimental/om-manifest-validator/validator"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Run", func() {
+	var manifest *bosh.Manifest
+
+	BeforeEach(func() {
+		manifest = &bosh.Manifest{
+			InstanceGroups: []*bosh.InstanceGroup{
+				bosh.NewInstanceGroup("router", []*bosh.Job{bosh.NewJob("gorouter")}),
+			},
+		}
+	})
+
+	It("returns the findings of every rule stamped with the rule ID", func() {
+		singleInstance := validator.NewRule("single-instance", "instance groups run more than one instance", validator.Warning, func(m *bosh.Manifest) []validator.Finding {
+			var findings []validator.Finding
+			for _, ig := range m.InstanceGroups {
+				if ig.Instances() < 2 {
+					findings = append(findings, validator.Finding{
+						Message:  "only one instance",
+						Location: validator.Location{InstanceGroup: ig.Name()},
+					})
+				}
+			}
+			return findings
+		})
+		noTLS := validator.NewRule("router-tls", "the router terminates TLS", validator.Error, func(m *bosh.Manifest) []validator.Finding {
+			return []validator.Finding{{
+				Severity: validator.Info,
+				Message:  "TLS is disabled",
+				Location: validator.Location{InstanceGroup: "router", Job: "gorouter", Property: "router.enable_ssl"},
+			}}
+		})
+
+		report := validator.Run(manifest, singleInstance, noTLS)
+
+		Expect(report.Findings).To(Equal([]validator.Finding{
+			{
+				RuleID:   "single-instance",
+				Severity: validator.Warning,
+				Message:  "only one instance",
+				Location: validator.Location{InstanceGroup: "router"},
+			},
+			{
+				RuleID:   "router-tls",
+				Severity: validator.Info,
+				Message:  "TLS is disabled",
+				Location: validator.Location{InstanceGroup: "router", Job: "gorouter", Property: "router.enable_ssl"},
+			},
+		}))
+		Expect(report.HasErrors()).To(BeFalse())
+		Expect(report.Count(validator.Warning)).To(Equal(1))
+		Expect(report.Findings[1].String()).To(Equal("[info] router-tls: TLS is disabled (router/gorouter:router.enable_ssl)"))
+	})
+
+	It("reports a rule that panics as an error", func() {
+		missingJob := validator.NewRule("missing-job", "", validator.Warning, func(m *bosh.Manifest) []validator.Finding {
+			m.MustFindInstanceGroupNamed("router").MustFindJob("nonexistent")
+			return nil
+		})
+
+		report := validator.Run(manifest, missingJob)
+
+		Expect(report.HasErrors()).To(BeTrue())
+		Expect(report.Findings[0].RuleID).To(Equal("missing-job"))
+		Expect(report.Findings[0].Message).To(ContainSubstring("Unable to find job named: 'nonexistent'"))
+	})
+})
+
+var _ = Describe("ParseSeverity", func() {
+	It("parses the severity names", func() {
+		for _, s := range []validator.Severity{validator.Info, validator.Warning, validator.Error} {
+			parsed, err := validator.ParseSeverity(s.String())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsed).To(Equal(s))
+		}
+	})
+
+	It("rejects unknown severities", func() {
+		_, err := validator.ParseSeverity("fatal")
+		Expect(err).To(MatchError(`unknown severity "fatal"`))
+	})
+})